@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// archiveFS is a file system held in memory, as read from a tar archive,
+// with files by their slash separated paths.
+type archiveFS map[string]*archiveFile
+
+type archiveFile struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// add adds the file or directory, and the directories containing it.
+func (fsys archiveFS) add(name string, data []byte, mode fs.FileMode, modTime time.Time) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return
+	}
+	fsys[name] = &archiveFile{name: path.Base(name), data: data, mode: mode, modTime: modTime}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := fsys[dir]; !ok {
+			fsys[dir] = &archiveFile{name: path.Base(dir), mode: fs.ModeDir | 0755, modTime: modTime}
+		}
+	}
+}
+
+func (fsys archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file := fsys[name]
+	if name == "." {
+		file = &archiveFile{name: ".", mode: fs.ModeDir | 0755}
+	}
+	if file == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !file.IsDir() {
+		return &archiveReader{file, bytes.NewReader(file.data)}, nil
+	}
+	entries := []fs.DirEntry{}
+	for entry, f := range fsys {
+		if path.Dir(entry) == name {
+			entries = append(entries, f)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &archiveDir{file, entries}, nil
+}
+
+func (f *archiveFile) Name() string               { return f.name }
+func (f *archiveFile) Size() int64                { return int64(len(f.data)) }
+func (f *archiveFile) Mode() fs.FileMode          { return f.mode }
+func (f *archiveFile) ModTime() time.Time         { return f.modTime }
+func (f *archiveFile) IsDir() bool                { return f.mode.IsDir() }
+func (f *archiveFile) Sys() interface{}           { return nil }
+func (f *archiveFile) Type() fs.FileMode          { return f.mode.Type() }
+func (f *archiveFile) Info() (fs.FileInfo, error) { return f, nil }
+
+// archiveReader is an open file of an archiveFS.
+type archiveReader struct {
+	file *archiveFile
+	*bytes.Reader
+}
+
+func (r *archiveReader) Stat() (fs.FileInfo, error) { return r.file, nil }
+func (r *archiveReader) Close() error               { return nil }
+
+// archiveDir is an open directory of an archiveFS.
+type archiveDir struct {
+	file    *archiveFile
+	entries []fs.DirEntry
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.file, nil }
+func (d *archiveDir) Close() error               { return nil }
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.file.name, Err: fs.ErrInvalid}
+}
+
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
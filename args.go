@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/makeshiftd/groom/groom"
+)
+
+// version is set when building releases, with
+// -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+// options holds the options understood by groom itself, they override the
+// options of the configuration file.
+type options struct {
+	now string
+	cwd bool
+	env bool
+	// envPrefix limits the environment exposed as data to the
+	// variables with names starting with the prefix.
+	envPrefix string
+	// delims are the left and right action delimiters separated by
+	// a space, as in "[[ ]]".
+	delims string
+	// mode removes the whitespace around block actions.
+	mode groom.Mode
+	// output is the file written instead of standard output.
+	output string
+	// plugins are the directories searched for plugins, separated as
+	// in PATH, which is searched when empty.
+	plugins string
+	// paths are the directories searched for templates.
+	paths []string
+	// load are the names of data sets or data files, merged into the
+	// data in order.
+	load []string
+	// template is the name of the template executed instead of the
+	// first template.
+	template string
+	// listTemplates prints the names and files of the templates instead
+	// of executing them.
+	listTemplates bool
+	// help and version print the usage or the version instead.
+	help    bool
+	version bool
+}
+
+// option describes a command line option.
+type option struct {
+	name  string
+	short string
+	// arg names the value of the option, which is then required, unless
+	// optional when the value may only follow an '='.
+	arg      string
+	optional bool
+	usage    string
+	set      func(args *parsedArgs, value string) error
+}
+
+// parsedArgs are the results of parsing the command line.
+type parsedArgs struct {
+	data  map[string]interface{}
+	paths []string
+	opts  options
+	// searchPaths and load replace, rather than add to, the options of
+	// the configuration.
+	searchPaths []string
+	load        []string
+}
+
+// OPTIONS are the command line options, in the order of the usage. The
+// options starting with "no-" turn off those set by the configuration.
+var OPTIONS = []option{
+	{name: "help", short: "h", usage: "show this help and exit",
+		set: func(a *parsedArgs, _ string) error { a.opts.help = true; return nil }},
+	{name: "version", usage: "show the version and exit",
+		set: func(a *parsedArgs, _ string) error { a.opts.version = true; return nil }},
+	{name: "template", short: "t", arg: "name", usage: "execute the named template instead of the first",
+		set: func(a *parsedArgs, v string) error { a.opts.template = v; return nil }},
+	{name: "list-templates", usage: "list the templates with their files and exit",
+		set: func(a *parsedArgs, _ string) error { a.opts.listTemplates = true; return nil }},
+	{name: "set", arg: "key=value", usage: "set the data value of key, may be repeated",
+		set: setData},
+	{name: "load", arg: "name", usage: "merge a data set or data file into the data, may be repeated",
+		set: func(a *parsedArgs, v string) error { a.load = append(a.load, v); return nil }},
+	{name: "env", arg: "prefix", optional: true, usage: "expose the environment variables, with the prefix, as .Env",
+		set: func(a *parsedArgs, v string) error { a.opts.env = true; a.opts.envPrefix = v; return nil }},
+	{name: "no-env", usage: "do not expose the environment variables",
+		set: func(a *parsedArgs, _ string) error { a.opts.env = false; a.opts.envPrefix = ""; return nil }},
+	{name: "now", arg: "time", usage: "set the time of the now function, or SOURCE_DATE_EPOCH",
+		set: func(a *parsedArgs, v string) error { a.opts.now = v; return nil }},
+	{name: "delims", arg: "'left right'", usage: "set the action delimiters, as in '[[ ]]'",
+		set: func(a *parsedArgs, v string) error { a.opts.delims = v; return nil }},
+	{name: "trim-blocks", usage: "remove the first newline after block actions",
+		set: func(a *parsedArgs, _ string) error { a.opts.mode |= groom.TrimBlocks; return nil }},
+	{name: "no-trim-blocks", usage: "keep the first newline after block actions",
+		set: func(a *parsedArgs, _ string) error { a.opts.mode &^= groom.TrimBlocks; return nil }},
+	{name: "lstrip-blocks", usage: "remove the indentation before block actions",
+		set: func(a *parsedArgs, _ string) error { a.opts.mode |= groom.LstripBlocks; return nil }},
+	{name: "no-lstrip-blocks", usage: "keep the indentation before block actions",
+		set: func(a *parsedArgs, _ string) error { a.opts.mode &^= groom.LstripBlocks; return nil }},
+	{name: "path", arg: "dir", usage: "search the directory for templates, may be repeated",
+		set: func(a *parsedArgs, v string) error { a.searchPaths = append(a.searchPaths, v); return nil }},
+	{name: "cwd", usage: "resolve the paths of file functions against the working directory",
+		set: func(a *parsedArgs, _ string) error { a.opts.cwd = true; return nil }},
+	{name: "no-cwd", usage: "resolve the paths of file functions against the template",
+		set: func(a *parsedArgs, _ string) error { a.opts.cwd = false; return nil }},
+	{name: "output", short: "o", arg: "file", usage: "write to the file instead of standard output",
+		set: func(a *parsedArgs, v string) error { a.opts.output = v; return nil }},
+	{name: "plugins", arg: "dirs", usage: "search the directories for plugins instead of PATH",
+		set: func(a *parsedArgs, v string) error { a.opts.plugins = v; return nil }},
+}
+
+func setData(a *parsedArgs, value string) error {
+	idx := strings.Index(value, "=")
+	if idx <= 0 {
+		return fmt.Errorf("groom: invalid --set: %q, expected key=value", value)
+	}
+	a.data[value[:idx]] = value[idx+1:]
+	return nil
+}
+
+func findOption(name string, short bool) *option {
+	for idx := range OPTIONS {
+		opt := &OPTIONS[idx]
+		if (!short && opt.name == name) || (short && opt.short != "" && opt.short == name) {
+			return opt
+		}
+	}
+	return nil
+}
+
+// LEGACY_DATA_REGEXP matches the legacy form of data arguments, as in
+// "--greeting=Hello", which are still accepted, with a warning, for
+// options not known.
+var LEGACY_DATA_REGEXP = regexp.MustCompile("^--?([^=]+?)\\s*=\\s*(.*?)\\s*$")
+
+// parseArgs parses the options and template paths of the command line,
+// starting from the options of the configuration. An argument "--" ends
+// the options and "-" is the path of standard input.
+//
+// Arguments that set data before there were options, as in "--draft",
+// "--greeting=Hello" or "--version=1.2", still do when they can mean
+// nothing else, with a deprecation warning written to warn so that
+// mistyped options are noticed.
+func parseArgs(args []string, opts options, warn io.Writer) (map[string]interface{}, []string, options, error) {
+	parsed := &parsedArgs{data: map[string]interface{}{}, paths: []string{}, opts: opts}
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+		debug("Parse Arg:", arg)
+		if arg == "--" {
+			parsed.paths = append(parsed.paths, args[idx+1:]...)
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			parsed.paths = append(parsed.paths, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.TrimLeft(arg, "-"), "", false
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value, hasValue = name[:eq], name[eq+1:], true
+		}
+		opt := findOption(name, !strings.HasPrefix(arg, "--"))
+		if opt == nil || (opt.arg == "" && hasValue) {
+			if match := LEGACY_DATA_REGEXP.FindStringSubmatch(arg); match != nil {
+				debug("Match Data: '%s'='%s'", match[1], match[2])
+				fmt.Fprintf(warn, "groom: deprecated: %s sets data, use --set %s=%s\n", arg, match[1], match[2])
+				parsed.data[match[1]] = match[2]
+				continue
+			}
+			if name != "" {
+				debug("Match Data: '%s'=''", name)
+				fmt.Fprintf(warn, "groom: deprecated: %s sets data, use --set %s=\n", arg, name)
+				parsed.data[name] = ""
+				continue
+			}
+			return nil, nil, opts, fmt.Errorf("groom: unknown option: %s", arg)
+		}
+
+		if opt.arg != "" && !hasValue && !opt.optional {
+			if idx+1 >= len(args) {
+				return nil, nil, opts, fmt.Errorf("groom: option requires %s: %s", opt.arg, arg)
+			}
+			idx++
+			value = args[idx]
+		}
+		debug("Match Option: %s='%s'", opt.name, value)
+		if err := opt.set(parsed, value); err != nil {
+			return nil, nil, opts, err
+		}
+	}
+	if len(parsed.searchPaths) > 0 {
+		parsed.opts.paths = parsed.searchPaths
+	}
+	if len(parsed.load) > 0 {
+		parsed.opts.load = parsed.load
+	}
+	return parsed.data, parsed.paths, parsed.opts, nil
+}
+
+// usage writes the usage of the command line.
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: groom [options] [template...]
+       groom compile [options] [-o bundle.grmc] template|dir...
+       groom run [options] bundle.grmc
+       groom build [options] [target...]
+
+Renders the first template, with the others parsed for it to use, or
+else the template named by --template, to standard output. The template
+is read from standard input when none, or "-", is given. A first path
+ending in .zip, .tar, .tar.gz or .tgz is an archive containing the
+templates, by default index.grm.
+
+The compile command writes the templates as a bundle, which run renders
+without parsing them again. The build command renders the targets of the
+groom.yaml or .groomrc file in the working directory or its parents.
+
+Options:
+`)
+	for _, opt := range OPTIONS {
+		flag := "    --" + opt.name
+		if opt.short != "" {
+			flag = "-" + opt.short + ", --" + opt.name
+		}
+		switch {
+		case opt.optional:
+			flag += "[=" + opt.arg + "]"
+		case opt.arg != "":
+			flag += " " + opt.arg
+		}
+		fmt.Fprintf(w, "  %-28s %s\n", flag, opt.usage)
+	}
+	fmt.Fprint(w, `
+Options end at "--", after which all arguments are template paths.
+
+Before there were options, --output=file and the like set data values,
+as --set output=file does now. Other arguments, such as --greeting=Hello,
+--draft or --version=1.2, still set data, with a deprecation warning.
+`)
+}
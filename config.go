@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/makeshiftd/groom/groom"
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_FILES are the names of the project configuration file, looked
+// for in the working directory and then in each of its parents.
+var CONFIG_FILES = []string{"groom.yaml", ".groomrc"}
+
+// config is a project configuration file, as in:
+//
+//	options:
+//	  delims: "[[ ]]"
+//	  paths: [layouts]
+//	  load: [site]
+//	data:
+//	  site: data/site.yaml
+//	  draft:
+//	    draft: true
+//	targets:
+//	  docs:
+//	    templates: [docs/index.grm]
+//	    load: [site, draft]
+//	    output: public/docs.html
+//
+// Relative paths in the file are relative to its directory.
+type config struct {
+	path string
+	// Options are the defaults of the command line options.
+	Options configOptions `yaml:"options"`
+	// Data are named data sets, either values or the path of a data file.
+	Data map[string]interface{} `yaml:"data"`
+	// Targets are the renderings done by "groom build".
+	Targets map[string]configTarget `yaml:"targets"`
+}
+
+// configOptions are the command line options set by a configuration.
+type configOptions struct {
+	Now string `yaml:"now"`
+	Cwd bool   `yaml:"cwd"`
+	// Env is true, or the prefix of the environment variables exposed.
+	Env          interface{} `yaml:"env"`
+	Delims       string      `yaml:"delims"`
+	TrimBlocks   bool        `yaml:"trim-blocks"`
+	LstripBlocks bool        `yaml:"lstrip-blocks"`
+	Output       string      `yaml:"output"`
+	Plugins      []string    `yaml:"plugins"`
+	Paths        []string    `yaml:"paths"`
+	Load         []string    `yaml:"load"`
+}
+
+// configTarget is a rendering of templates, with options overriding those
+// of the configuration.
+type configTarget struct {
+	configOptions `yaml:",inline"`
+	Templates     []string `yaml:"templates"`
+}
+
+// findConfig returns the configuration in the directory or the nearest of
+// its parents, or nil when there is none.
+func findConfig(dir string) (*config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		for _, name := range CONFIG_FILES {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return loadConfig(path)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func loadConfig(path string) (*config, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{path: path}
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && len(bytes.TrimSpace(buf)) > 0 {
+		return nil, fmt.Errorf("groom: %s: %s", path, err)
+	}
+	debug("Config:", path)
+	return cfg, nil
+}
+
+// resolve returns the path relative to the directory of the configuration.
+func (cfg *config) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(cfg.path), path)
+}
+
+func (cfg *config) resolveAll(paths []string) []string {
+	resolved := make([]string, len(paths))
+	for idx, path := range paths {
+		resolved[idx] = cfg.resolve(path)
+	}
+	return resolved
+}
+
+// options returns the options set by the configuration, and by the target
+// if it is not nil.
+func (cfg *config) options(target *configTarget) (options, error) {
+	opts := options{}
+	if cfg == nil {
+		return opts, nil
+	}
+	if err := cfg.apply(&opts, cfg.Options); err != nil {
+		return opts, err
+	}
+	if target != nil {
+		if err := cfg.apply(&opts, target.configOptions); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// apply sets the options set by co.
+func (cfg *config) apply(opts *options, co configOptions) error {
+	if co.Now != "" {
+		opts.now = co.Now
+	}
+	if co.Cwd {
+		opts.cwd = true
+	}
+	switch env := co.Env.(type) {
+	case nil:
+	case bool:
+		opts.env = env
+	case string:
+		opts.env = true
+		opts.envPrefix = env
+	default:
+		return fmt.Errorf("groom: %s: invalid env: %v", cfg.path, env)
+	}
+	if co.Delims != "" {
+		opts.delims = co.Delims
+	}
+	if co.TrimBlocks {
+		opts.mode |= groom.TrimBlocks
+	}
+	if co.LstripBlocks {
+		opts.mode |= groom.LstripBlocks
+	}
+	if co.Output != "" {
+		opts.output = cfg.resolve(co.Output)
+	}
+	if len(co.Plugins) > 0 {
+		opts.plugins = strings.Join(cfg.resolveAll(co.Plugins), string(os.PathListSeparator))
+	}
+	if len(co.Paths) > 0 {
+		opts.paths = cfg.resolveAll(co.Paths)
+	}
+	if len(co.Load) > 0 {
+		opts.load = co.Load
+	}
+	return nil
+}
+
+// targetNames returns the names of the targets in order.
+func (cfg *config) targetNames() []string {
+	names := []string{}
+	if cfg != nil {
+		for name := range cfg.Targets {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadData returns the values of the data set, or else of the data file,
+// named.
+func (cfg *config) loadData(r *groom.Renderer, name string) (map[string]interface{}, error) {
+	var value interface{}
+	path := name
+	if cfg != nil {
+		if set, ok := cfg.Data[name]; ok {
+			if file, isPath := set.(string); isPath {
+				path = cfg.resolve(file)
+			} else {
+				value, path = set, ""
+			}
+		}
+	}
+	if path != "" {
+		v, err := r.LoadData(path)
+		if err != nil {
+			return nil, err
+		}
+		value = v
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("groom: data %s is not a map", name)
+	}
+	return values, nil
+}
@@ -1,57 +1,244 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"text/tabwriter"
+	"time"
 
-	"github.com/makeshiftd/groom/internal/template"
+	"github.com/makeshiftd/groom/groom"
 	"github.com/xyplane/debugger"
 )
 
 var debug = debugger.Debug("groom")
 
 func main() {
-	os.Exit(groom(os.Args[1:]))
+	os.Exit(run(os.Args[1:]))
 }
 
-func groom(args []string) int {
+// commands other than rendering templates, given as the first argument.
+var commands = map[string]bool{
+	// compile parses templates and writes them as a bundle.
+	"compile": true,
+	// run renders the main template of a bundle.
+	"run": true,
+	// build renders the targets of the configuration named, or all.
+	"build": true,
+}
+
+func run(args []string) int {
+
+	command := ""
+	if len(args) > 0 && commands[args[0]] {
+		command = args[0]
+		args = args[1:]
+	}
+
+	_, names, flags, err := parseArgs(args, options{}, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "Run 'groom --help' for usage.")
+		return 2
+	}
+	if flags.help {
+		usage(os.Stdout)
+		return 0
+	}
+	if flags.version {
+		fmt.Println("groom", version)
+		return 0
+	}
+
+	cfg, err := findConfig(".")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if command == "build" {
+		if len(names) == 0 {
+			names = cfg.targetNames()
+		}
+		if len(names) == 0 {
+			fmt.Fprintln(os.Stderr, "groom: no targets to build")
+			return 1
+		}
+		if flags.output != "" && len(names) > 1 {
+			fmt.Fprintln(os.Stderr, "groom: --output requires a single target to build")
+			return 2
+		}
+		for _, name := range names {
+			target, ok := configTarget{}, false
+			if cfg != nil {
+				target, ok = cfg.Targets[name]
+			}
+			if !ok {
+				fmt.Fprintf(os.Stderr, "groom: no target: %s\n", name)
+				return 1
+			}
+			if len(target.Templates) == 0 {
+				fmt.Fprintf(os.Stderr, "groom: target has no templates: %s\n", name)
+				return 1
+			}
+			debug("Build Target:", name)
+			opts, err := cfg.options(&target)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			data, _, opts, _ := parseArgs(args, opts, ioutil.Discard)
+			if code := render("", data, cfg.resolveAll(target.Templates), opts, cfg); code != 0 {
+				return code
+			}
+		}
+		return 0
+	}
 
-	data, paths := parseArgs(args)
+	opts, err := cfg.options(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	data, paths, opts, _ := parseArgs(args, opts, ioutil.Discard)
+	return render(command, data, paths, opts, cfg)
+}
+
+// render renders the templates, or runs the command on them, with the
+// options and data, adding the data sets of the options.
+func render(command string, data map[string]interface{}, paths []string, opts options, cfg *config) int {
 
 	debug("Data:", data)
 	debug("Paths:", paths)
 
-	var err error
-	var tmpl *template.Template
+	now, err := nowOption(opts.now)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if opts.env {
+		data["Env"] = groom.Environ(opts.envPrefix)
+	}
+
+	var delims []string
+	if opts.delims != "" {
+		delims = strings.Fields(opts.delims)
+		if len(delims) != 2 {
+			fmt.Fprintf(os.Stderr, "groom: invalid delimiters: %q\n", opts.delims)
+			return 1
+		}
+	} else {
+		delims = []string{"", ""}
+	}
+
+	r := groom.New().
+		Now(now).
+		CwdPaths(opts.cwd).
+		Delims(delims[0], delims[1]).
+		Mode(opts.mode).
+		SearchPath(opts.paths...)
+	defer r.Close()
+
+	if len(opts.load) > 0 {
+		sets := map[string]interface{}{}
+		for _, name := range opts.load {
+			values, derr := cfg.loadData(r, name)
+			if derr != nil {
+				fmt.Fprintln(os.Stderr, derr)
+				return 1
+			}
+			for key, value := range values {
+				sets[key] = value
+			}
+		}
+		for key, value := range data {
+			sets[key] = value
+		}
+		data = sets
+	}
 
-	if len(paths) == 0 {
-		buf, rerr := ioutil.ReadAll(os.Stdin)
-		if rerr != nil {
+	var pluginDirs []string
+	if opts.plugins != "" {
+		pluginDirs = filepath.SplitList(opts.plugins)
+	}
+	for _, path := range groom.FindPlugins(pluginDirs...) {
+		debug("Start Plugin:", path)
+		if _, err = r.Plugin(path); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return 1
 		}
+	}
+
+	if len(paths) > 0 && isBundle(paths[0]) {
+		debug("Open Bundle:", paths[0])
+		bundle, berr := openBundle(paths[0])
+		if berr != nil {
+			fmt.Fprintln(os.Stderr, berr)
+			return 1
+		}
+		if closer, ok := bundle.(io.Closer); ok {
+			defer closer.Close()
+		}
+		r.FS(bundle)
+		paths = paths[1:]
+		if len(paths) == 0 {
+			paths = []string{"index.grm"}
+		}
+	}
 
-		name := "stdin.grm"
-		path := filepath.Join(".", name)
-		tmpl, err = template.New(funcs, false).ParseText(name, path, string(buf))
+	if command == "run" {
+		if len(paths) != 1 {
+			fmt.Fprintln(os.Stderr, "groom: run requires one bundle")
+			return 1
+		}
+		debug("Read Bundle:", paths[0])
+		file, ferr := os.Open(paths[0])
+		if ferr != nil {
+			fmt.Fprintln(os.Stderr, ferr)
+			return 1
+		}
+		_, err = r.ReadBundle(file)
+		file.Close()
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return 1
 		}
 	} else {
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
 		for _, path := range paths {
-			name := filepath.Base(path)
-			if tmpl == nil {
-				debug("New Parse File:", name, path)
-				tmpl, err = template.New(funcs, false).ParseFile(name, path)
-			} else {
-				debug("Parse File:", name, path)
-				_, err = tmpl.ParseFile(name, path)
+			if path == "-" {
+				buf, rerr := ioutil.ReadAll(os.Stdin)
+				if rerr != nil {
+					fmt.Fprintln(os.Stderr, rerr)
+					return 1
+				}
+
+				name := "stdin.grm"
+				_, err = r.ParseText(name, filepath.Join(".", name), string(buf))
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 1
+				}
+				continue
+			}
+			if command == "compile" {
+				// A directory is compiled from its index template.
+				if info, serr := os.Stat(path); serr == nil && info.IsDir() {
+					path = filepath.Join(path, "index.grm")
+				}
 			}
+			debug("Parse File:", path)
+			_, err = r.ParseFile(path)
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				return 1
@@ -59,7 +246,41 @@ func groom(args []string) int {
 		}
 	}
 
-	err = tmpl.Execute(os.Stdout, data)
+	if opts.listTemplates {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, tmpl := range r.Templates() {
+			path := tmpl.Path
+			if path == "" {
+				path = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", tmpl.Name, path)
+		}
+		tw.Flush()
+		return 0
+	}
+
+	out := os.Stdout
+	if opts.output != "" {
+		if err = os.MkdirAll(filepath.Dir(opts.output), 0755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		out, err = os.Create(opts.output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer out.Close()
+	}
+
+	switch {
+	case command == "compile":
+		err = r.WriteBundle(out)
+	case opts.template != "":
+		err = r.Output(out).RenderTemplate(opts.template, data)
+	default:
+		err = r.Output(out).Render(data)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return 1
@@ -68,27 +289,69 @@ func groom(args []string) int {
 	return 0
 }
 
-var ARG_DATA_REGEX = regexp.MustCompile("^--?((.*)\\s*=\\s*(.*?)\\s*|(.*?)\\s*)$")
-
-func parseArgs(args []string) (map[string]string, []string) {
-	data := map[string]string{}
-	paths := []string{}
-	for _, arg := range args {
-		debug("Parse Arg:", arg)
-		if !strings.HasPrefix(arg, "-") {
-			paths = append(paths, arg)
-			continue
-		}
-		match := ARG_DATA_REGEX.FindStringSubmatch(arg)
-		if len(match) > 0 {
-			if match[2] != "" {
-				debug("Match Data: '%s'='%s'", match[2], match[3])
-				data[match[2]] = match[3]
-			} else if match[4] != "" {
-				debug("Match Data: '%s'=''", match[4])
-				data[match[4]] = ""
+// nowOption returns the time set by the --now switch or else by the
+// SOURCE_DATE_EPOCH environment variable, so that output is reproducible.
+func nowOption(value string) (time.Time, error) {
+	if value == "" {
+		value = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return groom.ParseTime(value)
+}
+
+// isBundle reports whether the path names an archive of templates, in
+// which case the following paths name the templates to render within it.
+func isBundle(path string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// openBundle opens the zip archive, which is then an io.Closer, or reads
+// the tar archive, optionally gzip compressed, into memory.
+func openBundle(path string) (fs.FS, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return zip.OpenReader(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if !strings.HasSuffix(strings.ToLower(path), ".tar") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("groom: %s: %s", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	bundle := archiveFS{}
+	archive := tar.NewReader(reader)
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("groom: %s: %s", path, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			bundle.add(hdr.Name, nil, fs.ModeDir|hdr.FileInfo().Mode().Perm(), hdr.ModTime)
+		case tar.TypeReg:
+			buf, err := ioutil.ReadAll(archive)
+			if err != nil {
+				return nil, fmt.Errorf("groom: %s: %s", path, err)
 			}
+			bundle.add(hdr.Name, buf, hdr.FileInfo().Mode().Perm(), hdr.ModTime)
 		}
 	}
-	return data, paths
+	return bundle, nil
 }
@@ -0,0 +1,133 @@
+package groom
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/makeshiftd/groom/internal/template"
+)
+
+// bundleVersion is the version of the bundle format, written on the first
+// line of bundles, as in "groom bundle 1". The encoding of the templates is
+// versioned separately, in the header.
+const bundleVersion = 1
+
+// bundleHeader follows the first line of a bundle as a line of JSON.
+type bundleHeader struct {
+	// Encoding is the version of the encoding of the templates.
+	Encoding int `json:"encoding"`
+	// Main is the name of the template executed by Render.
+	Main string `json:"main"`
+	// Sources are the files the templates were parsed from.
+	Sources []bundleSource `json:"sources"`
+}
+
+type bundleSource struct {
+	// Path is absolute, unless the file was read from the file system
+	// given to FS.
+	Path string `json:"path"`
+	// Hash is the SHA-256 of the file when the bundle was written, empty
+	// when the file could not be read, as for standard input.
+	Hash string `json:"hash,omitempty"`
+}
+
+func hashSource(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteBundle writes the templates parsed so far, with their imports
+// resolved, as a bundle that ReadBundle loads without parsing them again.
+func (r *Renderer) WriteBundle(w io.Writer) error {
+	if r.main == nil {
+		return errors.New("groom: no template parsed")
+	}
+	header := bundleHeader{Encoding: template.EncodingVersion, Main: r.main.Name(), Sources: []bundleSource{}}
+	seen := map[string]bool{}
+	for _, tmpl := range r.main.Templates() {
+		path := tmpl.Path()
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		source := bundleSource{Path: path}
+		if buf, err := r.readFile(path); err == nil {
+			source.Hash = hashSource(buf)
+			if r.fsys == nil {
+				if source.Path, err = filepath.Abs(path); err != nil {
+					return err
+				}
+			}
+		}
+		header.Sources = append(header.Sources, source)
+	}
+	buf, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "groom bundle %d\n%s\n", bundleVersion, buf); err != nil {
+		return err
+	}
+	return r.main.Encode(w)
+}
+
+// ReadBundle loads the templates of a bundle written by WriteBundle. The
+// bundle is stale, and an error returned, when it was written by another
+// version of groom or when any of its source files has since changed or
+// been removed. The functions called by the templates must be defined, as
+// when parsing them.
+func (r *Renderer) ReadBundle(rd io.Reader) (*Renderer, error) {
+	br := bufio.NewReader(rd)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, errors.New("groom: invalid bundle")
+	}
+	var version int
+	if _, err := fmt.Sscanf(line, "groom bundle %d\n", &version); err != nil {
+		return nil, errors.New("groom: invalid bundle")
+	}
+	if version != bundleVersion {
+		return nil, fmt.Errorf("groom: stale bundle: version %d, expected %d", version, bundleVersion)
+	}
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return nil, errors.New("groom: invalid bundle")
+	}
+	var header bundleHeader
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &header); err != nil {
+		return nil, fmt.Errorf("groom: invalid bundle: %s", err)
+	}
+	if header.Encoding != template.EncodingVersion {
+		return nil, fmt.Errorf("groom: stale bundle: encoding %d, expected %d", header.Encoding, template.EncodingVersion)
+	}
+	for _, source := range header.Sources {
+		if source.Hash == "" {
+			continue
+		}
+		buf, err := r.readFile(source.Path)
+		if err != nil {
+			return nil, fmt.Errorf("groom: stale bundle: %s", err)
+		}
+		if hashSource(buf) != source.Hash {
+			return nil, fmt.Errorf("groom: stale bundle: %s has changed", source.Path)
+		}
+	}
+	tmpl, err := r.template().Decode(br, header.Main)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, fmt.Errorf("groom: invalid bundle: no template %q", header.Main)
+	}
+	if r.main == nil {
+		r.main = tmpl
+	}
+	return r, nil
+}
@@ -0,0 +1,263 @@
+package groom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/makeshiftd/groom/internal/template"
+)
+
+var funcs = template.FuncMap{
+	"json":     jsonFunc,
+	"stdin":    stdinFunc,
+	"str":      strFunc,
+	"markdown": markdownFunc,
+
+	// Collections
+	"list":           listFunc,
+	"dict":           dictFunc,
+	"set":            setFunc,
+	"get":            getFunc,
+	"keys":           keysFunc,
+	"values":         valuesFunc,
+	"sortBy":         sortByFunc,
+	"uniq":           uniqFunc,
+	"first":          firstFunc,
+	"last":           lastFunc,
+	"rest":           restFunc,
+	"slice":          sliceFunc,
+	"append":         appendFunc,
+	"merge":          mergeFunc,
+	"mergeOverwrite": mergeOverwriteFunc,
+	"pluck":          pluckFunc,
+	"where":          whereFunc,
+
+	// Math
+	"add":       addFunc,
+	"sub":       subFunc,
+	"mul":       mulFunc,
+	"div":       divFunc,
+	"mod":       modFunc,
+	"min":       minFunc,
+	"max":       maxFunc,
+	"floor":     floorFunc,
+	"ceil":      ceilFunc,
+	"round":     roundFunc,
+	"humanize":  humanizeFunc,
+	"thousands": thousandsFunc,
+	"percent":   percentFunc,
+	"fixed":     fixedFunc,
+
+	// Date and time
+	"date":      dateFunc,
+	"parseTime": parseTimeFunc,
+	"unixEpoch": unixEpochFunc,
+	"duration":  durationFunc,
+	"dateAdd":   dateAddFunc,
+	"dateDiff":  dateDiffFunc,
+	"inZone":    inZoneFunc,
+
+	// Encoding and hashing
+	"base64enc": base64encFunc,
+	"base64dec": base64decFunc,
+	"hex":       hexFunc,
+	"urlencode": urlencodeFunc,
+	"urldecode": urldecodeFunc,
+	"sha1":      sha1Func,
+	"sha256":    sha256Func,
+	"md5":       md5Func,
+	"crc32":     crc32Func,
+	"uuidv4":    uuidv4Func,
+	"uuidv5":    uuidv5Func,
+
+	// Data formats
+	"toYaml":       toYamlFunc,
+	"fromYaml":     fromYamlFunc,
+	"toToml":       toTomlFunc,
+	"fromToml":     fromTomlFunc,
+	"toPrettyJson": toPrettyJsonFunc,
+	"toXml":        toXmlFunc,
+	"fromXml":      fromXmlFunc,
+	"toCsv":        toCsvFunc,
+	"fromCsv":      fromCsvFunc,
+
+	// Environment
+	"env":         envFunc,
+	"envOr":       envOrFunc,
+	"requiredEnv": requiredEnvFunc,
+	"expandenv":   expandenvFunc,
+
+	// Markdown
+	"markdownToc": markdownTocFunc,
+	"markdownDoc": markdownDocFunc,
+
+	// HTML
+	"stripTags":    stripTagsFunc,
+	"sanitizeHTML": sanitizeHTMLFunc,
+	"excerpt":      excerptFunc,
+	"absURLs":      absURLsFunc,
+	"htmlUnescape": htmlUnescapeFunc,
+}
+
+// funcMap returns the functions available to a single render: the shared
+// funcs, those depending on the options of the renderer, those that keep
+// state for the duration of the render and finally those added by Funcs.
+func (r *Renderer) funcMap() template.FuncMap {
+	fm := template.FuncMap{}
+	for name, fn := range funcs {
+		fm[name] = fn
+	}
+	fm["cat"] = r.catFunc
+	fm["exec"] = r.execFunc
+	fm["now"] = r.nowFunc
+
+	// Filesystem
+	fm["glob"] = r.globFunc
+	fm["ls"] = r.lsFunc
+	fm["exists"] = r.existsFunc
+	fm["stat"] = r.statFunc
+	fm["readLines"] = r.readLinesFunc
+	fm["walk"] = r.walkFunc
+
+	for name, fn := range newRegexFuncs() {
+		fm[name] = fn
+	}
+	for name, fn := range newHighlightFuncs(r.catFunc) {
+		fm[name] = fn
+	}
+	for name, fn := range r.funcs {
+		fm[name] = fn
+	}
+	return fm
+}
+
+// catBytes is the content of the files read by cat, with the path of the
+// first, so that highlight can pick the language from its extension. It is
+// formatted as, and accepted by the functions in place of, a []byte.
+type catBytes struct {
+	data []byte
+	path string
+}
+
+// Format formats the content as a []byte.
+func (c catBytes) Format(f fmt.State, verb rune) {
+	format := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			format += string(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		format += strconv.Itoa(width)
+	}
+	if prec, ok := f.Precision(); ok {
+		format += "." + strconv.Itoa(prec)
+	}
+	fmt.Fprintf(f, format+string(verb), c.data)
+}
+
+// plainArg returns the content of a catBytes argument as a []byte, or else
+// the argument.
+func plainArg(arg interface{}) interface{} {
+	if c, ok := arg.(catBytes); ok {
+		return c.data
+	}
+	return arg
+}
+
+// stringArg returns the content of a string or []byte argument.
+func stringArg(name string, arg interface{}) (string, error) {
+	switch arg := plainArg(arg).(type) {
+	case string:
+		return arg, nil
+	case []byte:
+		return string(arg), nil
+	default:
+		return "", fmt.Errorf("groom: %s: unsupported type: %T", name, arg)
+	}
+}
+
+// bytesArg returns the content of a string or []byte argument.
+func bytesArg(name string, arg interface{}) ([]byte, error) {
+	switch arg := plainArg(arg).(type) {
+	case string:
+		return []byte(arg), nil
+	case []byte:
+		return arg, nil
+	default:
+		return nil, fmt.Errorf("groom: %s: unsupported type: %T", name, arg)
+	}
+}
+
+func (r *Renderer) catFunc(ctx *template.Context, args ...interface{}) (catBytes, error) {
+	var out catBytes
+	for _, arg := range args {
+		path, err := stringArg("cat", arg)
+		if err != nil {
+			return catBytes{}, err
+		}
+		buf, err := r.readFile(r.resolvePath(ctx, path))
+		if err != nil {
+			return catBytes{}, err
+		}
+		if out.path == "" {
+			out.path = path
+		}
+		out.data = append(out.data, buf...)
+	}
+	return out, nil
+}
+
+// execFunc runs a command in the directory of the executing template.
+func (r *Renderer) execFunc(ctx *template.Context, args ...interface{}) ([]byte, error) {
+	var strargs []string
+	for _, arg := range args {
+		switch arg := plainArg(arg).(type) {
+		case string:
+			strargs = append(strargs, arg)
+		case []byte:
+			strargs = append(strargs, string(arg))
+		default:
+			return nil, fmt.Errorf("groom: exec: unsupported type: %T", arg)
+		}
+	}
+	cmd := exec.Command(strargs[0], strargs[1:]...)
+	// Templates read from a file system have no directory to run in.
+	if !r.cwd && r.fsys == nil {
+		cmd.Dir = templateDir(ctx)
+	}
+	return cmd.Output()
+}
+
+func jsonFunc(arg interface{}) (interface{}, error) {
+	var v interface{}
+	switch arg := plainArg(arg).(type) {
+	case string:
+		err := json.Unmarshal([]byte(arg), &v)
+		return v, err
+	case []byte:
+		err := json.Unmarshal(arg, &v)
+		return v, err
+	default:
+		return json.Marshal(arg)
+	}
+}
+
+func strFunc(arg interface{}) (string, error) {
+	switch arg := plainArg(arg).(type) {
+	case string:
+		return arg, nil
+	case []byte:
+		return string(arg), nil
+	default:
+		return "", fmt.Errorf("groom: str: unsupported type: %T", arg)
+	}
+}
+
+func stdinFunc() ([]byte, error) {
+	return ioutil.ReadAll(os.Stdin)
+}
@@ -0,0 +1,498 @@
+package groom
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+func listFunc(items ...interface{}) []interface{} {
+	list := make([]interface{}, len(items))
+	copy(list, items)
+	return list
+}
+
+func dictFunc(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("groom: dict: odd number of arguments")
+	}
+	dict := make(map[string]interface{}, len(pairs)/2)
+	for idx := 0; idx < len(pairs); idx += 2 {
+		key, err := keyValue("dict", pairs[idx])
+		if err != nil {
+			return nil, err
+		}
+		dict[key] = pairs[idx+1]
+	}
+	return dict, nil
+}
+
+func setFunc(dict map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	if dict == nil {
+		dict = map[string]interface{}{}
+	}
+	dict[key] = value
+	return dict
+}
+
+func getFunc(item interface{}, key string, def ...interface{}) (interface{}, error) {
+	if len(def) > 1 {
+		return nil, errors.New("groom: get: too many arguments")
+	}
+	value, ok := fieldValue(item, key)
+	if !ok || value == nil {
+		if len(def) > 0 {
+			return def[0], nil
+		}
+		return nil, nil
+	}
+	return value, nil
+}
+
+func keysFunc(item interface{}) ([]interface{}, error) {
+	v := indirectValue(reflect.ValueOf(item))
+	switch v.Kind() {
+	case reflect.Map:
+		keys := make([]interface{}, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.Interface())
+		}
+		if err := sortValues("keys", keys); err != nil {
+			return nil, err
+		}
+		return keys, nil
+	case reflect.Struct:
+		var keys []interface{}
+		for idx := 0; idx < v.NumField(); idx++ {
+			if field := v.Type().Field(idx); field.PkgPath == "" {
+				keys = append(keys, field.Name)
+			}
+		}
+		if err := sortValues("keys", keys); err != nil {
+			return nil, err
+		}
+		return keys, nil
+	case reflect.Invalid:
+		return []interface{}{}, nil
+	default:
+		return nil, fmt.Errorf("groom: keys: unsupported type: %T", item)
+	}
+}
+
+func valuesFunc(item interface{}) ([]interface{}, error) {
+	keys, err := keysFunc(item)
+	if err != nil {
+		return nil, err
+	}
+	v := indirectValue(reflect.ValueOf(item))
+	values := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		if v.Kind() == reflect.Map {
+			values[idx] = v.MapIndex(reflect.ValueOf(key)).Interface()
+		} else {
+			values[idx] = v.FieldByName(key.(string)).Interface()
+		}
+	}
+	return values, nil
+}
+
+func sortByFunc(field string, list interface{}) ([]interface{}, error) {
+	items, err := listValue("sortBy", list)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]interface{}, len(items))
+	copy(sorted, items)
+	var cerr error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := fieldValue(sorted[i], field)
+		b, _ := fieldValue(sorted[j], field)
+		c, err := compareValues(a, b)
+		if err != nil && cerr == nil {
+			cerr = fmt.Errorf("groom: sortBy: %s", err)
+		}
+		return c < 0
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+	return sorted, nil
+}
+
+func uniqFunc(list interface{}) ([]interface{}, error) {
+	items, err := listValue("uniq", list)
+	if err != nil {
+		return nil, err
+	}
+	uniq := []interface{}{}
+	for _, item := range items {
+		if !containsValue(uniq, item) {
+			uniq = append(uniq, item)
+		}
+	}
+	return uniq, nil
+}
+
+func firstFunc(list interface{}) (interface{}, error) {
+	items, err := listValue("first", list)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+func lastFunc(list interface{}) (interface{}, error) {
+	items, err := listValue("last", list)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return items[len(items)-1], nil
+}
+
+func restFunc(list interface{}) ([]interface{}, error) {
+	items, err := listValue("rest", list)
+	if err != nil || len(items) == 0 {
+		return []interface{}{}, err
+	}
+	return items[1:], nil
+}
+
+func sliceFunc(item interface{}, bounds ...int) (interface{}, error) {
+	if len(bounds) > 2 {
+		return nil, errors.New("groom: slice: too many arguments")
+	}
+	if s, ok := item.(string); ok {
+		start, end, err := sliceBounds(len(s), bounds)
+		if err != nil {
+			return nil, err
+		}
+		return s[start:end], nil
+	}
+	items, err := listValue("slice", item)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := sliceBounds(len(items), bounds)
+	if err != nil {
+		return nil, err
+	}
+	return items[start:end], nil
+}
+
+func sliceBounds(length int, bounds []int) (int, int, error) {
+	start, end := 0, length
+	if len(bounds) > 0 {
+		start = bounds[0]
+	}
+	if len(bounds) > 1 {
+		end = bounds[1]
+	}
+	if start < 0 || end > length || start > end {
+		return 0, 0, fmt.Errorf("groom: slice: index out of range: [%d:%d] with length %d", start, end, length)
+	}
+	return start, end, nil
+}
+
+func appendFunc(list interface{}, values ...interface{}) ([]interface{}, error) {
+	items, err := listValue("append", list)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(items)+len(values))
+	result = append(result, items...)
+	return append(result, values...), nil
+}
+
+func mergeFunc(dst interface{}, srcs ...interface{}) (map[string]interface{}, error) {
+	return mergeMaps("merge", false, dst, srcs)
+}
+
+func mergeOverwriteFunc(dst interface{}, srcs ...interface{}) (map[string]interface{}, error) {
+	return mergeMaps("mergeOverwrite", true, dst, srcs)
+}
+
+// mergeMaps deep merges the source maps into a copy of dst. Values already
+// present in the result are only replaced by later sources if overwrite is set.
+func mergeMaps(name string, overwrite bool, dst interface{}, srcs []interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for _, src := range append([]interface{}{dst}, srcs...) {
+		m, err := mapValue(name, src)
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeInto(name, overwrite, result, m); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func mergeInto(name string, overwrite bool, dst, src map[string]interface{}) error {
+	for key, value := range src {
+		if sub, ok := asMap(value); ok {
+			m := map[string]interface{}{}
+			if existing, ok := dst[key]; ok {
+				if existing, ok := existing.(map[string]interface{}); ok {
+					m = existing
+				} else if !overwrite {
+					continue
+				}
+			}
+			if err := mergeInto(name, overwrite, m, sub); err != nil {
+				return err
+			}
+			dst[key] = m
+			continue
+		}
+		if _, ok := dst[key]; !ok || overwrite {
+			dst[key] = value
+		}
+	}
+	return nil
+}
+
+func pluckFunc(field string, list interface{}) ([]interface{}, error) {
+	items, err := listValue("pluck", list)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for _, item := range items {
+		if value, ok := fieldValue(item, field); ok {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+func whereFunc(field string, value interface{}, list interface{}) ([]interface{}, error) {
+	items, err := listValue("where", list)
+	if err != nil {
+		return nil, err
+	}
+	matches := []interface{}{}
+	for _, item := range items {
+		if v, ok := fieldValue(item, field); ok && equalValues(v, value) {
+			matches = append(matches, item)
+		}
+	}
+	return matches, nil
+}
+
+// Helpers for working with the generic values produced by decoding
+// JSON (and friends) as well as regular Go values.
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func keyValue(name string, key interface{}) (string, error) {
+	switch key := plainArg(key).(type) {
+	case string:
+		return key, nil
+	case []byte:
+		return string(key), nil
+	default:
+		return "", fmt.Errorf("groom: %s: unsupported key type: %T", name, key)
+	}
+}
+
+func listValue(name string, list interface{}) ([]interface{}, error) {
+	if items, ok := list.([]interface{}); ok {
+		return items, nil
+	}
+	v := indirectValue(reflect.ValueOf(list))
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, v.Len())
+		for idx := range items {
+			items[idx] = v.Index(idx).Interface()
+		}
+		return items, nil
+	case reflect.Invalid:
+		return []interface{}{}, nil
+	default:
+		return nil, fmt.Errorf("groom: %s: unsupported type: %T", name, list)
+	}
+}
+
+func mapValue(name string, item interface{}) (map[string]interface{}, error) {
+	if item == nil {
+		return map[string]interface{}{}, nil
+	}
+	if m, ok := asMap(item); ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("groom: %s: unsupported type: %T", name, item)
+}
+
+// asMap converts maps keyed by strings to map[string]interface{}.
+func asMap(item interface{}) (map[string]interface{}, bool) {
+	if m, ok := item.(map[string]interface{}); ok {
+		return m, true
+	}
+	v := indirectValue(reflect.ValueOf(item))
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	m := make(map[string]interface{}, v.Len())
+	for _, key := range v.MapKeys() {
+		m[key.String()] = v.MapIndex(key).Interface()
+	}
+	return m, true
+}
+
+// fieldValue returns the named map entry or struct field of item. The
+// name may be a dotted path to select nested values, for example "a.b".
+func fieldValue(item interface{}, name string) (interface{}, bool) {
+	for _, part := range strings.Split(name, ".") {
+		v := indirectValue(reflect.ValueOf(item))
+		switch v.Kind() {
+		case reflect.Map:
+			key := reflect.ValueOf(part)
+			if !key.Type().ConvertibleTo(v.Type().Key()) {
+				return nil, false
+			}
+			value := v.MapIndex(key.Convert(v.Type().Key()))
+			if !value.IsValid() {
+				return nil, false
+			}
+			item = value.Interface()
+		case reflect.Struct:
+			field, ok := v.Type().FieldByName(part)
+			if !ok || field.PkgPath != "" {
+				return nil, false
+			}
+			item = v.FieldByIndex(field.Index).Interface()
+		default:
+			return nil, false
+		}
+	}
+	return item, true
+}
+
+// numberValue returns the value of an integer or floating point number.
+// The boolean result reports whether the number is an integer.
+func numberValue(item interface{}) (i int64, f float64, isInt bool, ok bool) {
+	v := indirectValue(reflect.ValueOf(item))
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), float64(v.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(v.Uint()), float64(v.Uint()), true, true
+	case reflect.Float32, reflect.Float64:
+		return int64(v.Float()), v.Float(), false, true
+	}
+	return 0, 0, false, false
+}
+
+// compareValues orders numbers, strings, booleans and times. Nil values
+// are ordered before all other values.
+func compareValues(a, b interface{}) (int, error) {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0, nil
+		case a == nil:
+			return -1, nil
+		default:
+			return 1, nil
+		}
+	}
+	if ai, af, aInt, ok := numberValue(a); ok {
+		if bi, bf, bInt, ok := numberValue(b); ok {
+			if aInt && bInt {
+				return compareInts(ai, bi), nil
+			}
+			return compareFloats(af, bf), nil
+		}
+	}
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0, nil
+			case !a:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1, nil
+			case a.After(b):
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("incomparable types: %T and %T", a, b)
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// equalValues reports whether two values are equal, treating integer and
+// floating point numbers of the same value as equal.
+func equalValues(a, b interface{}) bool {
+	if c, err := compareValues(a, b); err == nil {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func containsValue(items []interface{}, item interface{}) bool {
+	for _, i := range items {
+		if equalValues(i, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortValues(name string, items []interface{}) error {
+	var cerr error
+	sort.SliceStable(items, func(i, j int) bool {
+		c, err := compareValues(items[i], items[j])
+		if err != nil && cerr == nil {
+			cerr = fmt.Errorf("groom: %s: %s", name, err)
+		}
+		return c < 0
+	})
+	return cerr
+}
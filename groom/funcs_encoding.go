@@ -0,0 +1,142 @@
+package groom
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"strings"
+)
+
+func base64encFunc(arg interface{}) (string, error) {
+	buf, err := bytesArg("base64enc", arg)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+func base64decFunc(arg interface{}) (string, error) {
+	s, err := stringArg("base64dec", arg)
+	if err != nil {
+		return "", err
+	}
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("groom: base64dec: %s", err)
+	}
+	return string(buf), nil
+}
+
+func hexFunc(arg interface{}) (string, error) {
+	buf, err := bytesArg("hex", arg)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func urlencodeFunc(arg interface{}) (string, error) {
+	s, err := stringArg("urlencode", arg)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryEscape(s), nil
+}
+
+func urldecodeFunc(arg interface{}) (string, error) {
+	s, err := stringArg("urldecode", arg)
+	if err != nil {
+		return "", err
+	}
+	s, err = url.QueryUnescape(s)
+	if err != nil {
+		return "", fmt.Errorf("groom: urldecode: %s", err)
+	}
+	return s, nil
+}
+
+func sha1Func(arg interface{}) (string, error) {
+	buf, err := bytesArg("sha1", arg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sha256Func(arg interface{}) (string, error) {
+	buf, err := bytesArg("sha256", arg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func md5Func(arg interface{}) (string, error) {
+	buf, err := bytesArg("md5", arg)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func crc32Func(arg interface{}) (string, error) {
+	buf, err := bytesArg("crc32", arg)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(buf)), nil
+}
+
+func uuidv4Func() (string, error) {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return "", fmt.Errorf("groom: uuidv4: %s", err)
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40 // Version 4
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return formatUUID(uuid[:]), nil
+}
+
+// uuidNamespaces are the predefined name space IDs of RFC 4122.
+var uuidNamespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+// uuidv5Func returns the name based UUID of name within namespace, which
+// is either a UUID or one of "dns", "url", "oid" or "x500".
+func uuidv5Func(namespace string, name interface{}) (string, error) {
+	if ns, ok := uuidNamespaces[strings.ToLower(namespace)]; ok {
+		namespace = ns
+	}
+	ns, err := hex.DecodeString(strings.Replace(namespace, "-", "", -1))
+	if err != nil || len(ns) != 16 {
+		return "", fmt.Errorf("groom: uuidv5: invalid namespace: %q", namespace)
+	}
+	buf, err := bytesArg("uuidv5", name)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	h.Write(ns)
+	h.Write(buf)
+	uuid := h.Sum(nil)[:16]
+	uuid[6] = (uuid[6] & 0x0f) | 0x50 // Version 5
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return formatUUID(uuid), nil
+}
+
+func formatUUID(uuid []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
@@ -0,0 +1,47 @@
+package groom
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func envFunc(name string) string {
+	return os.Getenv(name)
+}
+
+func envOrFunc(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+func requiredEnvFunc(name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("groom: requiredEnv: environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func expandenvFunc(arg interface{}) (string, error) {
+	s, err := stringArg("expandenv", arg)
+	if err != nil {
+		return "", err
+	}
+	return os.ExpandEnv(s), nil
+}
+
+// Environ returns the environment variables whose names start with prefix.
+func Environ(prefix string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		idx := strings.IndexByte(kv, '=')
+		if idx <= 0 || !strings.HasPrefix(kv[:idx], prefix) {
+			continue
+		}
+		env[kv[:idx]] = kv[idx+1:]
+	}
+	return env
+}
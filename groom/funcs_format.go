@@ -0,0 +1,370 @@
+package groom
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func toYamlFunc(arg interface{}) (string, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(plainArg(arg)); err != nil {
+		return "", fmt.Errorf("groom: toYaml: %s", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("groom: toYaml: %s", err)
+	}
+	return buf.String(), nil
+}
+
+func fromYamlFunc(arg interface{}) (interface{}, error) {
+	buf, err := bytesArg("fromYaml", arg)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(buf, &v); err != nil {
+		return nil, fmt.Errorf("groom: fromYaml: %s", err)
+	}
+	return v, nil
+}
+
+func toTomlFunc(arg interface{}) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(tomlValue(plainArg(arg))); err != nil {
+		return "", fmt.Errorf("groom: toToml: %s", err)
+	}
+	return buf.String(), nil
+}
+
+// tomlValue converts whole float64 numbers, as produced by decoding JSON,
+// to integers so they are not written as TOML floats.
+func tomlValue(arg interface{}) interface{} {
+	switch arg := arg.(type) {
+	case float64:
+		if arg == math.Trunc(arg) && math.Abs(arg) < 1<<53 {
+			return int64(arg)
+		}
+	case []interface{}:
+		list := make([]interface{}, len(arg))
+		for idx, item := range arg {
+			list[idx] = tomlValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(arg))
+		for key, value := range arg {
+			m[key] = tomlValue(value)
+		}
+		return m
+	}
+	return arg
+}
+
+func fromTomlFunc(arg interface{}) (interface{}, error) {
+	s, err := stringArg("fromToml", arg)
+	if err != nil {
+		return nil, err
+	}
+	v := map[string]interface{}{}
+	if _, err := toml.Decode(s, &v); err != nil {
+		return nil, fmt.Errorf("groom: fromToml: %s", err)
+	}
+	return v, nil
+}
+
+// toPrettyJsonFunc marshals the last argument as indented JSON. An optional
+// first argument sets the indent as a number of spaces or a string.
+func toPrettyJsonFunc(args ...interface{}) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", errors.New("groom: toPrettyJson: wrong number of arguments")
+	}
+	indent := "  "
+	if len(args) == 2 {
+		switch arg := args[0].(type) {
+		case string:
+			indent = arg
+		default:
+			n, err := numberArg("toPrettyJson", arg)
+			if err != nil {
+				return "", err
+			}
+			indent = strings.Repeat(" ", int(n.i))
+		}
+	}
+	buf, err := json.MarshalIndent(plainArg(args[len(args)-1]), "", indent)
+	if err != nil {
+		return "", fmt.Errorf("groom: toPrettyJson: %s", err)
+	}
+	return string(buf), nil
+}
+
+// Maps are converted to and from XML elements using these keys for
+// attributes and character data, elements of lists become repeated
+// elements of the same name.
+const (
+	xmlAttrPrefix = "-"
+	xmlTextKey    = "#text"
+)
+
+// toXmlFunc encodes the last argument as XML. An optional first argument
+// names the root element, otherwise a map with a single key is used as
+// the root element or the root is named "root".
+func toXmlFunc(args ...interface{}) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", errors.New("groom: toXml: wrong number of arguments")
+	}
+	root, value := "", args[len(args)-1]
+	if len(args) == 2 {
+		name, err := stringArg("toXml", args[0])
+		if err != nil {
+			return "", err
+		}
+		root = name
+	} else if m, ok := asMap(value); ok && len(m) == 1 {
+		for key, v := range m {
+			root, value = key, v
+		}
+	} else {
+		root = "root"
+	}
+	buf := &bytes.Buffer{}
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	if err := encodeXML(enc, root, value); err != nil {
+		return "", fmt.Errorf("groom: toXml: %s", err)
+	}
+	if err := enc.Flush(); err != nil {
+		return "", fmt.Errorf("groom: toXml: %s", err)
+	}
+	return buf.String(), nil
+}
+
+func encodeXML(enc *xml.Encoder, name string, value interface{}) error {
+	value = plainArg(value)
+	if _, ok := value.([]byte); !ok {
+		if items, err := listValue("toXml", value); err == nil && value != nil {
+			for _, item := range items {
+				if err := encodeXML(enc, name, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	m, isMap := asMap(value)
+	var keys []string
+	if isMap {
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.HasPrefix(key, xmlAttrPrefix) {
+				attr := xml.Attr{Name: xml.Name{Local: key[len(xmlAttrPrefix):]}, Value: fmt.Sprint(m[key])}
+				start.Attr = append(start.Attr, attr)
+			}
+		}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch {
+	case isMap:
+		if text, ok := m[xmlTextKey]; ok {
+			if err := enc.EncodeToken(xml.CharData(fmt.Sprint(text))); err != nil {
+				return err
+			}
+		}
+		for _, key := range keys {
+			if key == xmlTextKey || strings.HasPrefix(key, xmlAttrPrefix) {
+				continue
+			}
+			if err := encodeXML(enc, key, m[key]); err != nil {
+				return err
+			}
+		}
+	case value != nil:
+		s, err := stringArg("toXml", value)
+		if err != nil {
+			s = fmt.Sprint(value)
+		}
+		if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// fromXmlFunc decodes XML to a map with the root element as its only key.
+// Elements with neither attributes nor children decode as strings.
+func fromXmlFunc(arg interface{}) (interface{}, error) {
+	buf, err := bytesArg("fromXml", arg)
+	if err != nil {
+		return nil, err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(buf))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("groom: fromXml: no root element")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("groom: fromXml: %s", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			value, err := decodeXML(dec, start)
+			if err != nil {
+				return nil, fmt.Errorf("groom: fromXml: %s", err)
+			}
+			return map[string]interface{}{start.Name.Local: value}, nil
+		}
+	}
+}
+
+func decodeXML(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		m[xmlAttrPrefix+attr.Name.Local] = attr.Value
+	}
+	text := &strings.Builder{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXML(dec, tok)
+			if err != nil {
+				return nil, err
+			}
+			name := tok.Name.Local
+			switch existing := m[name].(type) {
+			case nil:
+				m[name] = child
+			case []interface{}:
+				m[name] = append(existing, child)
+			default:
+				m[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m[xmlTextKey] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// toCsvFunc encodes a list of lists as CSV rows, or a list of maps as CSV
+// rows with a header row of the sorted map keys.
+func toCsvFunc(arg interface{}) (string, error) {
+	items, err := listValue("toCsv", plainArg(arg))
+	if err != nil {
+		return "", err
+	}
+	var header []string
+	if len(items) > 0 {
+		if _, ok := asMap(items[0]); ok {
+			seen := map[string]bool{}
+			for _, item := range items {
+				m, ok := asMap(item)
+				if !ok {
+					return "", fmt.Errorf("groom: toCsv: unsupported row type: %T", item)
+				}
+				for key := range m {
+					if !seen[key] {
+						seen[key] = true
+						header = append(header, key)
+					}
+				}
+			}
+			sort.Strings(header)
+		}
+	}
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if header != nil {
+		w.Write(header)
+	}
+	for _, item := range items {
+		var record []string
+		if header != nil {
+			m, _ := asMap(item)
+			for _, key := range header {
+				record = append(record, csvField(m[key]))
+			}
+		} else {
+			cells, err := listValue("toCsv", item)
+			if err != nil {
+				return "", err
+			}
+			for _, cell := range cells {
+				record = append(record, csvField(cell))
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("groom: toCsv: %s", err)
+	}
+	return buf.String(), nil
+}
+
+func csvField(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if s, err := stringArg("toCsv", value); err == nil {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// fromCsvFunc decodes CSV to a list of maps keyed by the header row.
+func fromCsvFunc(arg interface{}) ([]interface{}, error) {
+	buf, err := bytesArg("fromCsv", arg)
+	if err != nil {
+		return nil, err
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("groom: fromCsv: %s", err)
+	}
+	rows := []interface{}{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for idx, key := range header {
+			if idx < len(record) {
+				row[key] = record[idx]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
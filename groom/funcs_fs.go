@@ -0,0 +1,201 @@
+package groom
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/makeshiftd/groom/internal/template"
+)
+
+// fileEntry describes a file found by the filesystem functions. The path
+// is relative to the directory of the template, like the argument given.
+type fileEntry struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+func newFileEntry(path string, info os.FileInfo) fileEntry {
+	return fileEntry{
+		Name:    info.Name(),
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
+
+// templateDir returns the directory of the executing template.
+func templateDir(ctx *template.Context) string {
+	if ctx.Path() == "" {
+		return "."
+	}
+	return filepath.Dir(ctx.Path())
+}
+
+// resolvePath resolves path relative to the directory of the executing
+// template, as is done for imports, unless CwdPaths is set.
+func (r *Renderer) resolvePath(ctx *template.Context, path string) string {
+	if r.cwd || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(templateDir(ctx), path)
+}
+
+// relativePath reverses resolvePath for paths found by the functions.
+func (r *Renderer) relativePath(ctx *template.Context, path string) string {
+	if r.cwd {
+		return path
+	}
+	if rel, err := filepath.Rel(templateDir(ctx), path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// The file access of the functions goes through these methods, which use
+// the file system set by FS, if any.
+
+func (r *Renderer) readFile(path string) ([]byte, error) {
+	if r.fsys != nil {
+		return fs.ReadFile(r.fsys, template.FSPath(path))
+	}
+	return ioutil.ReadFile(path)
+}
+
+func (r *Renderer) stat(path string) (os.FileInfo, error) {
+	if r.fsys != nil {
+		return fs.Stat(r.fsys, template.FSPath(path))
+	}
+	return os.Stat(path)
+}
+
+func (r *Renderer) readDir(dir string) ([]os.FileInfo, error) {
+	if r.fsys == nil {
+		return ioutil.ReadDir(dir)
+	}
+	dirEntries, err := fs.ReadDir(r.fsys, template.FSPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(dirEntries))
+	for idx, entry := range dirEntries {
+		if infos[idx], err = entry.Info(); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func (r *Renderer) glob(pattern string) ([]string, error) {
+	if r.fsys != nil {
+		return fs.Glob(r.fsys, template.FSPath(pattern))
+	}
+	return filepath.Glob(pattern)
+}
+
+func (r *Renderer) walk(root string, fn filepath.WalkFunc) error {
+	if r.fsys == nil {
+		return filepath.Walk(root, fn)
+	}
+	return fs.WalkDir(r.fsys, template.FSPath(root), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(path, nil, err)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info, nil)
+	})
+}
+
+func (r *Renderer) globFunc(ctx *template.Context, pattern string) ([]string, error) {
+	matches, err := r.glob(r.resolvePath(ctx, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("groom: glob: %s", err)
+	}
+	paths := make([]string, len(matches))
+	for idx, match := range matches {
+		if filepath.IsAbs(pattern) {
+			paths[idx] = match
+		} else {
+			paths[idx] = r.relativePath(ctx, match)
+		}
+	}
+	return paths, nil
+}
+
+func (r *Renderer) lsFunc(ctx *template.Context, dir string) ([]fileEntry, error) {
+	infos, err := r.readDir(r.resolvePath(ctx, dir))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fileEntry, len(infos))
+	for idx, info := range infos {
+		entries[idx] = newFileEntry(filepath.Join(dir, info.Name()), info)
+	}
+	return entries, nil
+}
+
+func (r *Renderer) existsFunc(ctx *template.Context, path string) bool {
+	_, err := r.stat(r.resolvePath(ctx, path))
+	return err == nil
+}
+
+func (r *Renderer) statFunc(ctx *template.Context, path string) (fileEntry, error) {
+	info, err := r.stat(r.resolvePath(ctx, path))
+	if err != nil {
+		return fileEntry{}, err
+	}
+	return newFileEntry(path, info), nil
+}
+
+func (r *Renderer) readLinesFunc(ctx *template.Context, path string) ([]string, error) {
+	buf, err := r.readFile(r.resolvePath(ctx, path))
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(nil, len(buf)+1)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// walkFunc lists the files and directories below dir in lexical order.
+func (r *Renderer) walkFunc(ctx *template.Context, dir string) ([]fileEntry, error) {
+	root := r.resolvePath(ctx, dir)
+	if r.fsys != nil {
+		root = template.FSPath(root)
+	}
+	entries := []fileEntry{}
+	err := r.walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, newFileEntry(filepath.Join(dir, rel), info))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,146 @@
+package groom
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/makeshiftd/groom/internal/template"
+)
+
+// highlightStyle is the default style of the inline and ANSI formats and
+// of highlightCSS.
+const highlightStyle = "github"
+
+func newHighlightFuncs(cat func(*template.Context, ...interface{}) (catBytes, error)) template.FuncMap {
+	return template.FuncMap{
+		"highlight": highlightFunc,
+		"highlightFile": func(ctx *template.Context, path string, opts ...interface{}) (string, error) {
+			content, err := cat(ctx, path)
+			if err != nil {
+				return "", err
+			}
+			return highlightFunc(append(opts, content)...)
+		},
+		"highlightCSS": highlightCSSFunc,
+	}
+}
+
+// highlightFunc highlights the code given as its last argument. The
+// language is given before the code, or else detected from the extension
+// of the file the code was read from by cat, or else from the code itself.
+// An optional map of options selects the "format", one of "html" (with
+// classes, the default), "inline" (with inline styles) or "ansi", the
+// "style" and "lineNumbers".
+func highlightFunc(args ...interface{}) (string, error) {
+	opts, rest := highlightOptions(args)
+	if len(rest) == 0 || len(rest) > 2 {
+		return "", fmt.Errorf("groom: highlight: wrong number of arguments")
+	}
+	code, err := stringArg("highlight", rest[len(rest)-1])
+	if err != nil {
+		return "", err
+	}
+	var lexer chroma.Lexer
+	if content, ok := rest[len(rest)-1].(catBytes); ok && content.path != "" {
+		lexer = lexers.Match(filepath.Base(content.path))
+	}
+	if len(rest) == 2 {
+		lang, err := stringArg("highlight", rest[0])
+		if err != nil {
+			return "", err
+		}
+		if lexer = lexers.Get(lang); lexer == nil {
+			return "", fmt.Errorf("groom: highlight: unknown language: %s", lang)
+		}
+	}
+	return highlight("highlight", lexer, code, opts)
+}
+
+// highlightOptions separates the map of options from the other arguments.
+func highlightOptions(args []interface{}) (map[string]interface{}, []interface{}) {
+	var opts map[string]interface{}
+	var rest []interface{}
+	for _, arg := range args {
+		if m, ok := asMap(arg); ok && opts == nil {
+			opts = m
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return opts, rest
+}
+
+// highlight highlights the code with the lexer, or one detected from the
+// code when nil.
+func highlight(name string, lexer chroma.Lexer, code string, opts map[string]interface{}) (string, error) {
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	format, style, lineNumbers := "html", highlightStyle, false
+	for key, value := range opts {
+		var ok bool
+		switch key {
+		case "format":
+			format, ok = value.(string)
+		case "style":
+			style, ok = value.(string)
+		case "lineNumbers":
+			lineNumbers, ok = value.(bool)
+		default:
+			return "", fmt.Errorf("groom: %s: unknown option: %s", name, key)
+		}
+		if !ok {
+			return "", fmt.Errorf("groom: %s: option %s: unsupported type: %T", name, key, value)
+		}
+	}
+	var formatter chroma.Formatter
+	switch format {
+	case "html":
+		formatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithLineNumbers(lineNumbers))
+	case "inline":
+		formatter = chromahtml.New(chromahtml.WithLineNumbers(lineNumbers))
+	case "ansi":
+		formatter = formatters.TTY256
+	default:
+		return "", fmt.Errorf("groom: %s: unknown format: %s", name, format)
+	}
+	buf := &bytes.Buffer{}
+	if err := highlightCode(buf, lexer, formatter, styles.Get(style), code); err != nil {
+		return "", fmt.Errorf("groom: %s: %s", name, err)
+	}
+	return buf.String(), nil
+}
+
+// highlightCSSFunc returns the CSS for the classes of the "html" format,
+// in the given style or the default one.
+func highlightCSSFunc(args ...string) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("groom: highlightCSS: wrong number of arguments")
+	}
+	style := highlightStyle
+	if len(args) == 1 {
+		style = args[0]
+	}
+	buf := &bytes.Buffer{}
+	if err := chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(buf, styles.Get(style)); err != nil {
+		return "", fmt.Errorf("groom: highlightCSS: %s", err)
+	}
+	return buf.String(), nil
+}
+
+func highlightCode(out *bytes.Buffer, lexer chroma.Lexer, formatter chroma.Formatter, style *chroma.Style, code string) error {
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return err
+	}
+	return formatter.Format(out, style, it)
+}
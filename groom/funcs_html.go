@@ -0,0 +1,246 @@
+package groom
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	xhtml "golang.org/x/net/html"
+)
+
+// htmlText returns the text of an HTML fragment with the tags removed and
+// entities unescaped, ignoring the content of script and style elements.
+func htmlText(s string) string {
+	buf := &strings.Builder{}
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	skip := ""
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			return buf.String()
+		case xhtml.StartTagToken:
+			if name, _ := z.TagName(); skip == "" && (string(name) == "script" || string(name) == "style") {
+				skip = string(name)
+			}
+		case xhtml.EndTagToken:
+			if name, _ := z.TagName(); string(name) == skip {
+				skip = ""
+			}
+		case xhtml.TextToken:
+			if skip == "" {
+				buf.Write(z.Text())
+			}
+		}
+	}
+}
+
+func stripTagsFunc(arg interface{}) (string, error) {
+	s, err := stringArg("stripTags", arg)
+	if err != nil {
+		return "", err
+	}
+	return htmlText(s), nil
+}
+
+func htmlUnescapeFunc(arg interface{}) (string, error) {
+	s, err := stringArg("htmlUnescape", arg)
+	if err != nil {
+		return "", err
+	}
+	return html.UnescapeString(s), nil
+}
+
+// sanitizePolicies are the named policies of sanitizeHTML.
+var sanitizePolicies = map[string]func() *bluemonday.Policy{
+	"ugc":    bluemonday.UGCPolicy,
+	"strict": bluemonday.StrictPolicy,
+}
+
+// sanitizeHTMLFunc removes the elements and attributes not allowed by a
+// policy from the HTML given as its last argument. The optional policy is
+// "ugc" (the default) for user generated content, "strict" to remove all
+// tags, or a map from the allowed elements to lists of their allowed
+// attributes.
+func sanitizeHTMLFunc(args ...interface{}) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("groom: sanitizeHTML: wrong number of arguments")
+	}
+	s, err := stringArg("sanitizeHTML", args[len(args)-1])
+	if err != nil {
+		return "", err
+	}
+	policy := bluemonday.UGCPolicy()
+	if len(args) == 2 {
+		if policy, err = sanitizePolicy(args[0]); err != nil {
+			return "", err
+		}
+	}
+	return policy.Sanitize(s), nil
+}
+
+func sanitizePolicy(arg interface{}) (*bluemonday.Policy, error) {
+	if name, ok := arg.(string); ok {
+		newPolicy, ok := sanitizePolicies[name]
+		if !ok {
+			return nil, fmt.Errorf("groom: sanitizeHTML: unknown policy: %s", name)
+		}
+		return newPolicy(), nil
+	}
+	allow, ok := asMap(arg)
+	if !ok {
+		return nil, fmt.Errorf("groom: sanitizeHTML: unsupported policy type: %T", arg)
+	}
+	policy := bluemonday.NewPolicy()
+	for elem, value := range allow {
+		policy.AllowElements(elem)
+		if value == nil {
+			continue
+		}
+		attrs, err := listValue("sanitizeHTML", value)
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range attrs {
+			name, err := stringArg("sanitizeHTML", attr)
+			if err != nil {
+				return nil, err
+			}
+			policy.AllowAttrs(name).OnElements(elem)
+			if name == "href" || name == "src" {
+				policy.AllowStandardURLs()
+			}
+		}
+	}
+	return policy, nil
+}
+
+// voidElements have no end tag, so are never left open by excerpt.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// excerptFunc truncates the HTML to at most n characters of text, at a
+// word boundary, closing any elements left open. An ellipsis is appended
+// when the text is truncated.
+func excerptFunc(n int, arg interface{}) (string, error) {
+	s, err := stringArg("excerpt", arg)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	var open []string
+	count := 0
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		raw := string(z.Raw())
+		switch tt {
+		case xhtml.ErrorToken:
+			if z.Err() != io.EOF {
+				return "", fmt.Errorf("groom: excerpt: %s", z.Err())
+			}
+			return buf.String(), nil
+		case xhtml.StartTagToken:
+			name, _ := z.TagName()
+			if !voidElements[string(name)] {
+				open = append(open, string(name))
+			}
+		case xhtml.EndTagToken:
+			name, _ := z.TagName()
+			for idx := len(open) - 1; idx >= 0; idx-- {
+				if open[idx] == string(name) {
+					open = open[:idx]
+					break
+				}
+			}
+		case xhtml.TextToken:
+			text := []rune(string(z.Text()))
+			if count+len(text) > n {
+				buf.WriteString(html.EscapeString(truncateWords(text, n-count)))
+				buf.WriteString("…")
+				for idx := len(open) - 1; idx >= 0; idx-- {
+					buf.WriteString("</" + open[idx] + ">")
+				}
+				return buf.String(), nil
+			}
+			count += len(text)
+		}
+		buf.WriteString(raw)
+	}
+}
+
+// truncateWords returns at most n runes of text, cut at the last space.
+func truncateWords(text []rune, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	cut := n
+	if !unicode.IsSpace(text[n]) {
+		for cut > 0 && !unicode.IsSpace(text[cut-1]) {
+			cut--
+		}
+	}
+	return strings.TrimRightFunc(string(text[:cut]), unicode.IsSpace)
+}
+
+// urlAttrs are the attributes rewritten by absURLs.
+var urlAttrs = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"action": true,
+	"poster": true,
+}
+
+// absURLsFunc rewrites the relative URLs of links and embedded content in
+// the HTML to absolute URLs resolved against base.
+func absURLsFunc(base string, arg interface{}) (string, error) {
+	s, err := stringArg("absURLs", arg)
+	if err != nil {
+		return "", err
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("groom: absURLs: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		switch tt {
+		case xhtml.ErrorToken:
+			if z.Err() != io.EOF {
+				return "", fmt.Errorf("groom: absURLs: %s", z.Err())
+			}
+			return buf.String(), nil
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			raw := string(z.Raw())
+			tok := z.Token()
+			changed := false
+			for idx, attr := range tok.Attr {
+				if !urlAttrs[attr.Key] {
+					continue
+				}
+				ref, err := url.Parse(attr.Val)
+				if err != nil || ref.IsAbs() || strings.HasPrefix(attr.Val, "//") {
+					continue
+				}
+				tok.Attr[idx].Val = baseURL.ResolveReference(ref).String()
+				changed = true
+			}
+			if changed {
+				buf.WriteString(tok.String())
+			} else {
+				buf.WriteString(raw)
+			}
+			continue
+		}
+		buf.Write(z.Raw())
+	}
+}
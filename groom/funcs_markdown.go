@@ -0,0 +1,318 @@
+package groom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/russross/blackfriday"
+	"gopkg.in/yaml.v3"
+)
+
+// markdownOptions are the blackfriday flags and extensions selected by the
+// options given to the markdown functions. The defaults are those of
+// blackfriday.MarkdownCommon.
+type markdownOptions struct {
+	flags      int
+	extensions int
+	highlight  bool
+}
+
+const (
+	markdownCommonFlags = blackfriday.HTML_USE_XHTML |
+		blackfriday.HTML_USE_SMARTYPANTS |
+		blackfriday.HTML_SMARTYPANTS_FRACTIONS |
+		blackfriday.HTML_SMARTYPANTS_DASHES |
+		blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
+
+	markdownCommonExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+		blackfriday.EXTENSION_TABLES |
+		blackfriday.EXTENSION_FENCED_CODE |
+		blackfriday.EXTENSION_AUTOLINK |
+		blackfriday.EXTENSION_STRIKETHROUGH |
+		blackfriday.EXTENSION_SPACE_HEADERS |
+		blackfriday.EXTENSION_HEADER_IDS |
+		blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
+		blackfriday.EXTENSION_DEFINITION_LISTS
+)
+
+// markdownOptionBits maps the option names to the flags and extensions
+// they toggle.
+var markdownOptionBits = map[string]struct{ flags, extensions int }{
+	"gfm": {0, blackfriday.EXTENSION_TABLES |
+		blackfriday.EXTENSION_FENCED_CODE |
+		blackfriday.EXTENSION_AUTOLINK |
+		blackfriday.EXTENSION_STRIKETHROUGH |
+		blackfriday.EXTENSION_NO_INTRA_EMPHASIS},
+	"tables":     {0, blackfriday.EXTENSION_TABLES},
+	"footnotes":  {blackfriday.HTML_FOOTNOTE_RETURN_LINKS, blackfriday.EXTENSION_FOOTNOTES},
+	"headingIds": {0, blackfriday.EXTENSION_AUTO_HEADER_IDS},
+	"smartypants": {blackfriday.HTML_USE_SMARTYPANTS |
+		blackfriday.HTML_SMARTYPANTS_FRACTIONS |
+		blackfriday.HTML_SMARTYPANTS_DASHES |
+		blackfriday.HTML_SMARTYPANTS_LATEX_DASHES, 0},
+	"safe": {blackfriday.HTML_SKIP_HTML | blackfriday.HTML_SKIP_STYLE | blackfriday.HTML_SAFELINK, 0},
+	"toc":  {blackfriday.HTML_TOC, blackfriday.EXTENSION_AUTO_HEADER_IDS},
+}
+
+// markdownGroupOptions toggle several flags or extensions, they are
+// applied before the other options so those override them, as with
+// {"gfm": false, "tables": true}.
+var markdownGroupOptions = map[string]bool{"gfm": true, "safe": true, "toc": true}
+
+func newMarkdownOptions(name string, opts map[string]interface{}) (markdownOptions, error) {
+	mo := markdownOptions{flags: markdownCommonFlags, extensions: markdownCommonExtensions}
+	keys := make([]string, 0, len(opts))
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if markdownGroupOptions[keys[i]] != markdownGroupOptions[keys[j]] {
+			return markdownGroupOptions[keys[i]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		value := opts[key]
+		on, ok := value.(bool)
+		if !ok {
+			return mo, fmt.Errorf("groom: %s: option %s: unsupported type: %T", name, key, value)
+		}
+		if key == "highlight" {
+			mo.highlight = on
+			continue
+		}
+		bits, ok := markdownOptionBits[key]
+		if !ok {
+			return mo, fmt.Errorf("groom: %s: unknown option: %s", name, key)
+		}
+		if on {
+			mo.flags |= bits.flags
+			mo.extensions |= bits.extensions
+		} else {
+			mo.flags &^= bits.flags
+			mo.extensions &^= bits.extensions
+		}
+	}
+	return mo, nil
+}
+
+func (mo markdownOptions) render(input []byte) []byte {
+	var renderer blackfriday.Renderer = blackfriday.HtmlRenderer(mo.flags, "", "")
+	if mo.highlight {
+		renderer = highlightRenderer{renderer}
+	}
+	return blackfriday.MarkdownOptions(input, renderer, blackfriday.Options{Extensions: mo.extensions})
+}
+
+// markdownArgs separates the content from the options, which may be given
+// before or after it, so both `markdown $content $opts` and
+// `$content | markdown $opts` work.
+func markdownArgs(name string, args []interface{}) (interface{}, map[string]interface{}, error) {
+	var content interface{}
+	var opts map[string]interface{}
+	for _, arg := range args {
+		if m, ok := asMap(arg); ok && opts == nil {
+			opts = m
+		} else if content == nil {
+			content = arg
+		} else {
+			return nil, nil, fmt.Errorf("groom: %s: wrong number of arguments", name)
+		}
+	}
+	if content == nil {
+		return nil, nil, fmt.Errorf("groom: %s: missing content", name)
+	}
+	return content, opts, nil
+}
+
+// markdownRender renders the content of args, returning the same type of
+// string or []byte as given.
+func markdownRender(name string, args []interface{}, mode func(*markdownOptions)) (interface{}, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, fmt.Errorf("groom: %s: wrong number of arguments", name)
+	}
+	content, opts, err := markdownArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+	mo, err := newMarkdownOptions(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	if mode != nil {
+		mode(&mo)
+	}
+	switch content := plainArg(content).(type) {
+	case string:
+		return string(mo.render([]byte(content))), nil
+	case []byte:
+		return mo.render(content), nil
+	default:
+		return nil, fmt.Errorf("groom: %s: unsupported type: %T", name, content)
+	}
+}
+
+// markdownFunc renders Markdown to HTML, with an optional map of options:
+// "gfm", "tables", "footnotes", "headingIds", "smartypants", "safe", "toc"
+// and "highlight".
+func markdownFunc(args ...interface{}) (interface{}, error) {
+	return markdownRender("markdown", args, nil)
+}
+
+// markdownTocFunc renders only the table of contents of the Markdown. The
+// anchors match the heading IDs of markdown with "headingIds" or "toc".
+func markdownTocFunc(args ...interface{}) (interface{}, error) {
+	return markdownRender("markdownToc", args, func(mo *markdownOptions) {
+		mo.flags |= blackfriday.HTML_TOC | blackfriday.HTML_OMIT_CONTENTS
+		mo.extensions |= blackfriday.EXTENSION_AUTO_HEADER_IDS
+	})
+}
+
+// markdownDoc is the result of markdownDoc, the rendered HTML of a
+// document together with its front matter and the parts of it needed to
+// build an index.
+type markdownDoc struct {
+	Meta      map[string]interface{}
+	HTML      string
+	Headings  []markdownHeading
+	Summary   string
+	WordCount int
+}
+
+type markdownHeading struct {
+	Level int
+	Text  string
+	ID    string
+}
+
+var (
+	markdownHeadingRegexp   = regexp.MustCompile(`(?s)<h([1-6])(?: id="([^"]*)")?>(.*?)</h[1-6]>`)
+	markdownParagraphRegexp = regexp.MustCompile(`(?s)<p>(.*?)</p>`)
+)
+
+// markdownDocFunc renders Markdown like markdown, taking the same options,
+// and parses the YAML (between "---" lines) or TOML (between "+++" lines)
+// front matter at the start of the document into Meta. The headings
+// always have IDs.
+func markdownDocFunc(args ...interface{}) (*markdownDoc, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, errors.New("groom: markdownDoc: wrong number of arguments")
+	}
+	content, opts, err := markdownArgs("markdownDoc", args)
+	if err != nil {
+		return nil, err
+	}
+	mo, err := newMarkdownOptions("markdownDoc", opts)
+	if err != nil {
+		return nil, err
+	}
+	mo.extensions |= blackfriday.EXTENSION_AUTO_HEADER_IDS
+	input, err := bytesArg("markdownDoc", content)
+	if err != nil {
+		return nil, err
+	}
+	meta, body, err := frontMatter(input)
+	if err != nil {
+		return nil, fmt.Errorf("groom: markdownDoc: front matter: %s", err)
+	}
+	doc := &markdownDoc{
+		Meta:     meta,
+		HTML:     string(mo.render(body)),
+		Headings: []markdownHeading{},
+	}
+	for _, m := range markdownHeadingRegexp.FindAllStringSubmatch(doc.HTML, -1) {
+		doc.Headings = append(doc.Headings, markdownHeading{
+			Level: int(m[1][0] - '0'),
+			Text:  strings.TrimSpace(htmlText(m[3])),
+			ID:    m[2],
+		})
+	}
+	if m := markdownParagraphRegexp.FindStringSubmatch(doc.HTML); m != nil {
+		doc.Summary = strings.TrimSpace(htmlText(m[1]))
+	}
+	doc.WordCount = len(strings.Fields(htmlText(doc.HTML)))
+	return doc, nil
+}
+
+// frontMatter splits the front matter from the start of input, returning
+// it parsed along with the remaining body.
+func frontMatter(input []byte) (map[string]interface{}, []byte, error) {
+	meta := map[string]interface{}{}
+	for _, delim := range []string{"---", "+++"} {
+		if !bytes.HasPrefix(input, []byte(delim+"\n")) && !bytes.HasPrefix(input, []byte(delim+"\r\n")) {
+			continue
+		}
+		rest := input[bytes.IndexByte(input, '\n')+1:]
+		var matter, body []byte
+		for offset := 0; offset < len(rest); {
+			end := bytes.IndexByte(rest[offset:], '\n')
+			if end < 0 {
+				end = len(rest) - offset
+			} else {
+				end++
+			}
+			line := rest[offset : offset+end]
+			if string(bytes.TrimRight(line, "\r\n")) == delim {
+				matter, body = rest[:offset], rest[offset+end:]
+				break
+			}
+			offset += end
+		}
+		if matter == nil && body == nil {
+			return nil, nil, errors.New("missing closing " + delim)
+		}
+		var err error
+		if delim == "---" {
+			err = yaml.Unmarshal(matter, &meta)
+		} else {
+			_, err = toml.Decode(string(matter), &meta)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return meta, body, nil
+	}
+	return meta, input, nil
+}
+
+// highlightRenderer renders fenced code blocks of a known language as
+// syntax highlighted HTML annotated with classes to be styled by CSS.
+type highlightRenderer struct {
+	blackfriday.Renderer
+}
+
+func (r highlightRenderer) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	lang := strings.Fields(info)
+	if len(lang) == 0 || lexers.Get(lang[0]) == nil {
+		r.Renderer.BlockCode(out, text, info)
+		return
+	}
+	if out.Len() > 0 {
+		out.WriteByte('\n')
+	}
+	if err := highlightHTML(out, lang[0], string(text)); err != nil {
+		r.Renderer.BlockCode(out, text, info)
+	}
+}
+
+func highlightHTML(out *bytes.Buffer, lang, code string) error {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return errors.New("unknown language: " + lang)
+	}
+	buf := &bytes.Buffer{}
+	if err := highlightCode(buf, lexer, chromahtml.New(chromahtml.WithClasses(true)), styles.Fallback, code); err != nil {
+		return err
+	}
+	out.Write(buf.Bytes())
+	out.WriteByte('\n')
+	return nil
+}
@@ -0,0 +1,267 @@
+package groom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// number holds an argument of an arithmetic function. Integer arithmetic
+// is used while all arguments are integers (such as template constants),
+// otherwise arguments are promoted to float64 (such as decoded JSON).
+type number struct {
+	i     int64
+	f     float64
+	isInt bool
+}
+
+func (n number) value() interface{} {
+	if n.isInt {
+		if i := int(n.i); int64(i) == n.i {
+			return i
+		}
+		return n.i
+	}
+	return n.f
+}
+
+func intNumber(i int64) number {
+	return number{i: i, f: float64(i), isInt: true}
+}
+
+func floatNumber(f float64) number {
+	return number{i: int64(f), f: f}
+}
+
+func numberArg(name string, arg interface{}) (number, error) {
+	if i, f, isInt, ok := numberValue(arg); ok {
+		return number{i: i, f: f, isInt: isInt}, nil
+	}
+	var s string
+	switch arg := plainArg(arg).(type) {
+	case string:
+		s = arg
+	case []byte:
+		s = string(arg)
+	default:
+		return number{}, fmt.Errorf("groom: %s: unsupported type: %T", name, arg)
+	}
+	s = strings.TrimSpace(s)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return intNumber(i), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return number{}, fmt.Errorf("groom: %s: invalid number: %q", name, s)
+	}
+	return floatNumber(f), nil
+}
+
+func numberArgs(name string, args []interface{}) ([]number, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("groom: %s: missing arguments", name)
+	}
+	nums := make([]number, len(args))
+	for idx, arg := range args {
+		n, err := numberArg(name, arg)
+		if err != nil {
+			return nil, err
+		}
+		nums[idx] = n
+	}
+	return nums, nil
+}
+
+// reduceNumbers folds the arguments of an arithmetic function, using
+// the integer operation while both operands are integers.
+func reduceNumbers(name string, args []interface{}, iop func(a, b int64) int64, fop func(a, b float64) float64) (interface{}, error) {
+	nums, err := numberArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+	acc := nums[0]
+	for _, n := range nums[1:] {
+		if acc.isInt && n.isInt {
+			acc = intNumber(iop(acc.i, n.i))
+		} else {
+			acc = floatNumber(fop(acc.f, n.f))
+		}
+	}
+	return acc.value(), nil
+}
+
+func addFunc(args ...interface{}) (interface{}, error) {
+	return reduceNumbers("add", args,
+		func(a, b int64) int64 { return a + b },
+		func(a, b float64) float64 { return a + b })
+}
+
+func subFunc(a, b interface{}) (interface{}, error) {
+	return reduceNumbers("sub", []interface{}{a, b},
+		func(a, b int64) int64 { return a - b },
+		func(a, b float64) float64 { return a - b })
+}
+
+func mulFunc(args ...interface{}) (interface{}, error) {
+	return reduceNumbers("mul", args,
+		func(a, b int64) int64 { return a * b },
+		func(a, b float64) float64 { return a * b })
+}
+
+func divFunc(a, b interface{}) (interface{}, error) {
+	nums, err := numberArgs("div", []interface{}{a, b})
+	if err != nil {
+		return nil, err
+	}
+	if nums[1].f == 0 {
+		return nil, errors.New("groom: div: division by zero")
+	}
+	if nums[0].isInt && nums[1].isInt {
+		return intNumber(nums[0].i / nums[1].i).value(), nil
+	}
+	return nums[0].f / nums[1].f, nil
+}
+
+func modFunc(a, b interface{}) (interface{}, error) {
+	nums, err := numberArgs("mod", []interface{}{a, b})
+	if err != nil {
+		return nil, err
+	}
+	if nums[1].f == 0 {
+		return nil, errors.New("groom: mod: division by zero")
+	}
+	if nums[0].isInt && nums[1].isInt {
+		return intNumber(nums[0].i % nums[1].i).value(), nil
+	}
+	return math.Mod(nums[0].f, nums[1].f), nil
+}
+
+func minFunc(args ...interface{}) (interface{}, error) {
+	return reduceNumbers("min", args,
+		func(a, b int64) int64 {
+			if b < a {
+				return b
+			}
+			return a
+		},
+		math.Min)
+}
+
+func maxFunc(args ...interface{}) (interface{}, error) {
+	return reduceNumbers("max", args,
+		func(a, b int64) int64 {
+			if b > a {
+				return b
+			}
+			return a
+		},
+		math.Max)
+}
+
+func floorFunc(arg interface{}) (float64, error) {
+	n, err := numberArg("floor", arg)
+	return math.Floor(n.f), err
+}
+
+func ceilFunc(arg interface{}) (float64, error) {
+	n, err := numberArg("ceil", arg)
+	return math.Ceil(n.f), err
+}
+
+// roundFunc rounds half away from zero, optionally to a number of decimal
+// places given before the value, as in {{ .price | round 2 }}.
+func roundFunc(args ...interface{}) (float64, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return 0, errors.New("groom: round: wrong number of arguments")
+	}
+	n, err := numberArg("round", args[len(args)-1])
+	if err != nil {
+		return 0, err
+	}
+	places := 0
+	if len(args) == 2 {
+		p, err := numberArg("round", args[0])
+		if err != nil {
+			return 0, err
+		}
+		places = int(p.i)
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Round(n.f*scale) / scale, nil
+}
+
+var byteUnits = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+
+// humanizeFunc formats a byte count using SI units, for example "1.5 MB".
+func humanizeFunc(arg interface{}) (string, error) {
+	n, err := numberArg("humanize", arg)
+	if err != nil {
+		return "", err
+	}
+	size, unit := n.f, 0
+	for math.Abs(size) >= 1000 && unit < len(byteUnits)-1 {
+		size /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", size, byteUnits[unit]), nil
+	}
+	if math.Abs(size) < 10 {
+		return fmt.Sprintf("%.1f %s", size, byteUnits[unit]), nil
+	}
+	return fmt.Sprintf("%.0f %s", size, byteUnits[unit]), nil
+}
+
+// thousandsFunc formats a number with comma thousand separators.
+func thousandsFunc(arg interface{}) (string, error) {
+	n, err := numberArg("thousands", arg)
+	if err != nil {
+		return "", err
+	}
+	var s string
+	if n.isInt {
+		s = strconv.FormatInt(n.i, 10)
+	} else {
+		s = strconv.FormatFloat(n.f, 'f', -1, 64)
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	frac := ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		s, frac = s[:idx], s[idx:]
+	}
+	var b strings.Builder
+	for idx, r := range s {
+		if idx > 0 && (len(s)-idx)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac, nil
+}
+
+// percentFunc formats a ratio as a percentage, for example 0.125 as "12.5%".
+func percentFunc(arg interface{}) (string, error) {
+	n, err := numberArg("percent", arg)
+	if err != nil {
+		return "", err
+	}
+	pct := math.Round(n.f*100*100) / 100
+	return strconv.FormatFloat(pct, 'f', -1, 64) + "%", nil
+}
+
+// fixedFunc formats a number with a fixed number of decimal places.
+func fixedFunc(places int, arg interface{}) (string, error) {
+	n, err := numberArg("fixed", arg)
+	if err != nil {
+		return "", err
+	}
+	if places < 0 {
+		return "", fmt.Errorf("groom: fixed: invalid decimal places: %d", places)
+	}
+	return strconv.FormatFloat(n.f, 'f', places, 64), nil
+}
@@ -0,0 +1,115 @@
+package groom
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/makeshiftd/groom/internal/template"
+)
+
+// maxRegexPatterns bounds the patterns held by a regexCache, which lives
+// as long as its Renderer, it is emptied when full.
+const maxRegexPatterns = 256
+
+// regexCache holds the compiled patterns, so patterns used inside loops
+// are only compiled once.
+type regexCache struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func newRegexFuncs() template.FuncMap {
+	c := &regexCache{patterns: map[string]*regexp.Regexp{}}
+	return template.FuncMap{
+		"regexMatch":      c.matchFunc,
+		"regexFind":       c.findFunc,
+		"regexFindAll":    c.findAllFunc,
+		"regexReplaceAll": c.replaceAllFunc,
+		"regexSplit":      c.splitFunc,
+		"regexQuoteMeta":  regexQuoteMetaFunc,
+	}
+}
+
+func (c *regexCache) compile(name, pattern string) (*regexp.Regexp, error) {
+	c.Lock()
+	defer c.Unlock()
+	if re, ok := c.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("groom: %s: invalid pattern: %s", name, err)
+	}
+	if len(c.patterns) >= maxRegexPatterns {
+		c.patterns = map[string]*regexp.Regexp{}
+	}
+	c.patterns[pattern] = re
+	return re, nil
+}
+
+func (c *regexCache) args(name, pattern string, arg interface{}) (*regexp.Regexp, string, error) {
+	s, err := stringArg(name, arg)
+	if err != nil {
+		return nil, "", err
+	}
+	re, err := c.compile(name, pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	return re, s, nil
+}
+
+func (c *regexCache) matchFunc(pattern string, arg interface{}) (bool, error) {
+	re, s, err := c.args("regexMatch", pattern, arg)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+func (c *regexCache) findFunc(pattern string, arg interface{}) (string, error) {
+	re, s, err := c.args("regexFind", pattern, arg)
+	if err != nil {
+		return "", err
+	}
+	return re.FindString(s), nil
+}
+
+func (c *regexCache) findAllFunc(pattern string, arg interface{}) ([]string, error) {
+	re, s, err := c.args("regexFindAll", pattern, arg)
+	if err != nil {
+		return nil, err
+	}
+	matches := re.FindAllString(s, -1)
+	if matches == nil {
+		matches = []string{}
+	}
+	return matches, nil
+}
+
+// replaceAllFunc replaces matches of the pattern, expanding $1 or ${name}
+// in the replacement to the text of the submatch.
+func (c *regexCache) replaceAllFunc(pattern, repl string, arg interface{}) (string, error) {
+	re, s, err := c.args("regexReplaceAll", pattern, arg)
+	if err != nil {
+		return "", err
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+func (c *regexCache) splitFunc(pattern string, arg interface{}) ([]string, error) {
+	re, s, err := c.args("regexSplit", pattern, arg)
+	if err != nil {
+		return nil, err
+	}
+	return re.Split(s, -1), nil
+}
+
+func regexQuoteMetaFunc(arg interface{}) (string, error) {
+	s, err := stringArg("regexQuoteMeta", arg)
+	if err != nil {
+		return "", err
+	}
+	return regexp.QuoteMeta(s), nil
+}
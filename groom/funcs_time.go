@@ -0,0 +1,150 @@
+package groom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the time zone database so zone conversions do not depend
+	// on the host system.
+	_ "time/tzdata"
+)
+
+// ParseTime parses a time as seconds since the Unix epoch or in RFC 3339
+// format, as accepted by the time functions.
+func ParseTime(value string) (time.Time, error) {
+	t, err := parseTimeValue(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("groom: invalid time: %q", value)
+	}
+	return t, nil
+}
+
+// parseTimeValue parses a time as seconds since the Unix epoch or in
+// RFC 3339 format.
+func parseTimeValue(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func timeArg(name string, arg interface{}) (time.Time, error) {
+	switch arg := plainArg(arg).(type) {
+	case time.Time:
+		return arg, nil
+	case *time.Time:
+		return *arg, nil
+	case string:
+		t, err := parseTimeValue(arg)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("groom: %s: invalid time: %q", name, arg)
+		}
+		return t, nil
+	case []byte:
+		return timeArg(name, string(arg))
+	}
+	if i, f, isInt, ok := numberValue(arg); ok {
+		if isInt {
+			return time.Unix(i, 0).UTC(), nil
+		}
+		secs := int64(f)
+		return time.Unix(secs, int64((f-float64(secs))*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("groom: %s: unsupported type: %T", name, arg)
+}
+
+func durationArg(name string, arg interface{}) (time.Duration, error) {
+	switch arg := plainArg(arg).(type) {
+	case time.Duration:
+		return arg, nil
+	case string:
+		d, err := time.ParseDuration(strings.TrimSpace(arg))
+		if err != nil {
+			return 0, fmt.Errorf("groom: %s: invalid duration: %q", name, arg)
+		}
+		return d, nil
+	case []byte:
+		return durationArg(name, string(arg))
+	}
+	// Plain numbers are durations in seconds.
+	if _, f, _, ok := numberValue(arg); ok {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("groom: %s: unsupported type: %T", name, arg)
+}
+
+// nowFunc returns the time set by Now, or else the current time.
+func (r *Renderer) nowFunc() time.Time {
+	if r.now.IsZero() {
+		return time.Now()
+	}
+	return r.now
+}
+
+func dateFunc(layout string, arg interface{}) (string, error) {
+	t, err := timeArg("date", arg)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+func parseTimeFunc(layout, value string) (time.Time, error) {
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("groom: parseTime: %s", err)
+	}
+	return t, nil
+}
+
+func unixEpochFunc(arg interface{}) (int64, error) {
+	t, err := timeArg("unixEpoch", arg)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func durationFunc(arg interface{}) (time.Duration, error) {
+	return durationArg("duration", arg)
+}
+
+func dateAddFunc(d interface{}, arg interface{}) (time.Time, error) {
+	duration, err := durationArg("dateAdd", d)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := timeArg("dateAdd", arg)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(duration), nil
+}
+
+// dateDiffFunc returns the duration from the first time to the second.
+func dateDiffFunc(from, to interface{}) (time.Duration, error) {
+	start, err := timeArg("dateDiff", from)
+	if err != nil {
+		return 0, err
+	}
+	end, err := timeArg("dateDiff", to)
+	if err != nil {
+		return 0, err
+	}
+	return end.Sub(start), nil
+}
+
+func inZoneFunc(zone string, arg interface{}) (time.Time, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("groom: inZone: unknown time zone: %q", zone)
+	}
+	t, err := timeArg("inZone", arg)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
@@ -0,0 +1,310 @@
+// Package groom renders groom templates: Go text templates extended with
+// imports resolved relative to the importing template, the apply action
+// and a library of functions for generating files.
+//
+// A Renderer is configured with chained methods before parsing:
+//
+//	r := groom.New().SearchPath("templates").Output(w)
+//	if _, err := r.ParseFile("site.grm"); err != nil {
+//		return err
+//	}
+//	err := r.Render(data)
+package groom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makeshiftd/groom/internal/template"
+)
+
+// FuncMap maps names to functions callable from templates, as for
+// text/template. A function may declare a *Context as its first parameter
+// to be passed the execution context of the calling template.
+type FuncMap map[string]interface{}
+
+// Context describes the execution of the template calling a function.
+type Context = template.Context
+
+// Mode controls the whitespace around block actions, such as if, range
+// and end, so they may be written on lines of their own.
+type Mode = template.Mode
+
+const (
+	// TrimBlocks removes the first newline after a block action.
+	TrimBlocks = template.TrimBlocks
+	// LstripBlocks removes the spaces and tabs before a block action at
+	// the start of a line.
+	LstripBlocks = template.LstripBlocks
+)
+
+// Loader decodes the content of a data file.
+type Loader func(data []byte) (interface{}, error)
+
+// Renderer parses and executes templates. The options must be set before
+// the first template is parsed.
+type Renderer struct {
+	funcs   FuncMap
+	safe    bool
+	paths   []string
+	loaders map[string]Loader
+	out     io.Writer
+	delims  [2]string
+	mode    Mode
+	now     time.Time
+	cwd     bool
+	fsys    fs.FS
+	plugins []*plugin
+	tmpl    *template.Template
+	// main is the first template parsed.
+	main *template.Template
+}
+
+// New returns a renderer writing to standard output, with loaders for
+// JSON, YAML and TOML data files.
+func New() *Renderer {
+	return &Renderer{
+		funcs: FuncMap{},
+		loaders: map[string]Loader{
+			".json": loadJSON,
+			".yaml": loadYAML,
+			".yml":  loadYAML,
+			".toml": loadTOML,
+		},
+		out: os.Stdout,
+	}
+}
+
+func loadJSON(data []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func loadYAML(data []byte) (interface{}, error) {
+	return fromYamlFunc(data)
+}
+
+func loadTOML(data []byte) (interface{}, error) {
+	return fromTomlFunc(data)
+}
+
+// Funcs adds functions to those available to templates, replacing any of
+// the same name.
+func (r *Renderer) Funcs(funcs FuncMap) *Renderer {
+	for name, fn := range funcs {
+		r.funcs[name] = fn
+	}
+	return r
+}
+
+// Safe sets whether the output is escaped as HTML. Safe mode is not yet
+// supported, parsing fails when it is set.
+func (r *Renderer) Safe(safe bool) *Renderer {
+	r.safe = safe
+	return r
+}
+
+// SearchPath sets the directories searched for templates and imports that
+// are not found relative to the working directory or importing template.
+func (r *Renderer) SearchPath(dirs ...string) *Renderer {
+	r.paths = dirs
+	return r
+}
+
+// Loader sets the loader of data files with the extension, such as ".csv".
+// A nil loader removes the loader of the extension.
+func (r *Renderer) Loader(ext string, loader Loader) *Renderer {
+	if loader == nil {
+		delete(r.loaders, ext)
+	} else {
+		r.loaders[ext] = loader
+	}
+	return r
+}
+
+// Output sets the writer Render writes to.
+func (r *Renderer) Output(w io.Writer) *Renderer {
+	r.out = w
+	return r
+}
+
+// Delims sets the action delimiters of templates not overriding them with
+// a groom:delims first line. Empty delimiters are the default "{{" or "}}".
+func (r *Renderer) Delims(left, right string) *Renderer {
+	r.delims = [2]string{left, right}
+	return r
+}
+
+// Mode sets the whitespace control of templates.
+func (r *Renderer) Mode(mode ...Mode) *Renderer {
+	r.mode = 0
+	for _, m := range mode {
+		r.mode |= m
+	}
+	return r
+}
+
+// Now sets the time returned by the now function, so the output is
+// reproducible. The zero time means the current time.
+func (r *Renderer) Now(now time.Time) *Renderer {
+	r.now = now
+	return r
+}
+
+// CwdPaths sets whether the filesystem functions resolve relative paths
+// against the working directory, rather than the directory of the
+// executing template.
+func (r *Renderer) CwdPaths(cwd bool) *Renderer {
+	r.cwd = cwd
+	return r
+}
+
+// FS sets the file system templates, imports, data files and the files
+// read by the filesystem functions are found in, such as the contents of
+// a zip archive. Paths are then slash separated and relative to its root.
+// The exec function still runs commands on disk.
+func (r *Renderer) FS(fsys fs.FS) *Renderer {
+	r.fsys = fsys
+	return r
+}
+
+func (r *Renderer) template() *template.Template {
+	if r.tmpl == nil {
+		r.tmpl = template.New(r.funcMap(), r.safe, r.mode).
+			Delims(r.delims[0], r.delims[1]).
+			SearchPath(r.paths...).
+			FS(r.fsys)
+	}
+	return r.tmpl
+}
+
+// searchPath returns the path, or else the first search path directory
+// containing it.
+func (r *Renderer) searchPath(path string) string {
+	if r.fsys == nil && filepath.IsAbs(path) {
+		return path
+	}
+	if _, err := r.stat(path); err == nil {
+		return path
+	}
+	for _, dir := range r.paths {
+		p := filepath.Join(dir, path)
+		if _, err := r.stat(p); err == nil {
+			return p
+		}
+	}
+	return path
+}
+
+// ParseFile parses the template file, named by its base name. The first
+// template parsed is the one executed by Render.
+func (r *Renderer) ParseFile(path string) (*Renderer, error) {
+	path = r.searchPath(path)
+	tmpl, err := r.template().ParseFile(filepath.Base(path), path)
+	if err != nil {
+		return nil, err
+	}
+	if r.main == nil {
+		r.main = tmpl
+	}
+	return r, nil
+}
+
+// ParseText parses the template text, resolving its imports relative to
+// path.
+func (r *Renderer) ParseText(name, path, text string) (*Renderer, error) {
+	tmpl, err := r.template().ParseText(name, path, text)
+	if err != nil {
+		return nil, err
+	}
+	if r.main == nil {
+		r.main = tmpl
+	}
+	return r, nil
+}
+
+// Render executes the first template parsed, writing to the output.
+func (r *Renderer) Render(data interface{}) error {
+	return r.Execute(r.out, data)
+}
+
+// Execute executes the first template parsed, writing to w.
+func (r *Renderer) Execute(w io.Writer, data interface{}) error {
+	if r.main == nil {
+		return errors.New("groom: no template parsed")
+	}
+	return r.main.Execute(w, data)
+}
+
+// RenderTemplate executes the named template, writing to the output.
+func (r *Renderer) RenderTemplate(name string, data interface{}) error {
+	return r.ExecuteTemplate(r.out, name, data)
+}
+
+// ExecuteTemplate executes the named template, writing to w. The name may
+// be that of any template parsed, imported or defined. When there is none
+// of the name, the error lists the names of the templates.
+func (r *Renderer) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	if r.main == nil {
+		return errors.New("groom: no template parsed")
+	}
+	tmpl := r.main.Lookup(name)
+	if tmpl == nil {
+		names := []string{}
+		for _, t := range r.Templates() {
+			names = append(names, strconv.Quote(t.Name))
+		}
+		return fmt.Errorf("groom: no template %q, the templates are: %s", name, strings.Join(names, ", "))
+	}
+	return tmpl.Execute(w, data)
+}
+
+// Template describes a template parsed, imported or defined.
+type Template struct {
+	Name string
+	// Path is the file the template was parsed from, empty when it was
+	// not parsed from a file.
+	Path string
+}
+
+// Templates returns the templates parsed so far, sorted by name.
+func (r *Renderer) Templates() []Template {
+	tmpls := []Template{}
+	if r.main == nil {
+		return tmpls
+	}
+	for _, tmpl := range r.main.Templates() {
+		tmpls = append(tmpls, Template{Name: tmpl.Name(), Path: tmpl.Path()})
+	}
+	return tmpls
+}
+
+// LoadData loads the data file with the loader of its extension, looking
+// for the file like ParseFile.
+func (r *Renderer) LoadData(path string) (interface{}, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	loader, ok := r.loaders[ext]
+	if !ok {
+		return nil, fmt.Errorf("groom: no loader for data file: %s", path)
+	}
+	buf, err := r.readFile(r.searchPath(path))
+	if err != nil {
+		return nil, err
+	}
+	v, err := loader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("groom: %s: %s", path, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,290 @@
+package groom
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/russross/blackfriday"
+)
+
+func TestRenderer1(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	r := New().
+		Funcs(FuncMap{"shout": strings.ToUpper}).
+		SearchPath("test", "test/layouts").
+		Now(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)).
+		Output(buf)
+
+	if _, err := r.ParseFile("page.grm"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := r.LoadData("data.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Render(data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := `<h1>HELLO</h1>
+<p>2020-01-02</p>
+`
+	if buf.String() != result {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", buf.String(), result)
+	}
+}
+
+func TestRenderer2(t *testing.T) {
+
+	r := New().Loader(".txt", func(data []byte) (interface{}, error) {
+		return strings.Fields(string(data)), nil
+	})
+
+	if err := r.Execute(&bytes.Buffer{}, nil); err == nil {
+		t.Fatal("expected error executing without a template")
+	}
+
+	if _, err := r.LoadData("test/page.grm"); err == nil {
+		t.Fatal("expected error loading data without a loader")
+	}
+
+	if _, err := r.ParseText("fields", "fields.grm", `{{ range . }}[{{ . }}]{{ end }}`); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := r.LoadData("test/words.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := r.Execute(buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[a][b]" {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `[a][b]`\n", buf.String())
+	}
+}
+
+func TestRenderer3(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"pages/index.grm":  {Data: []byte(`{{ .title }}:{{ range ls "posts" }} {{ .Name }}{{ end }} {{ exists "posts/a.md" }} {{ cat "posts/b.md" | str }}`)},
+		"pages/posts/a.md": {Data: []byte("a")},
+		"pages/posts/b.md": {Data: []byte("b")},
+		"data/site.json":   {Data: []byte(`{"title": "Posts"}`)},
+	}
+
+	buf := &bytes.Buffer{}
+	r := New().FS(fsys).SearchPath("pages", "data").Output(buf)
+
+	if _, err := r.ParseFile("index.grm"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := r.LoadData("site.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Render(data); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Posts: a.md b.md true b" {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `Posts: a.md b.md true b`\n", buf.String())
+	}
+}
+
+func TestBundle1(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"index.grm":          {Data: []byte(`<h1>{{ template "import partials/title" . }}</h1>`)},
+		"partials/title.grm": {Data: []byte(`{{- shout .title -}}`)},
+	}
+	funcs := FuncMap{"shout": strings.ToUpper}
+
+	r := New().Funcs(funcs).FS(fsys)
+	if _, err := r.ParseFile("index.grm"); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := &bytes.Buffer{}
+	if err := r.WriteBundle(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(bundle.String(), "groom bundle 1\n") {
+		t.Fatalf("bundle has no version line: %q", bundle.String())
+	}
+
+	buf := &bytes.Buffer{}
+	r = New().Funcs(funcs).FS(fsys).Output(buf)
+	if _, err := r.ReadBundle(bytes.NewReader(bundle.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Render(map[string]string{"title": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<h1>HELLO</h1>" {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `<h1>HELLO</h1>`\n", buf.String())
+	}
+
+	fsys["partials/title.grm"] = &fstest.MapFile{Data: []byte(`{{ .title }}`)}
+	if _, err := New().FS(fsys).ReadBundle(bytes.NewReader(bundle.Bytes())); err == nil || !strings.Contains(err.Error(), "stale bundle") {
+		t.Fatal("expected stale bundle error, got:", err)
+	}
+
+	fsys["partials/title.grm"] = &fstest.MapFile{Data: []byte(`{{- shout .title -}}`)}
+	if _, err := New().FS(fsys).ReadBundle(bytes.NewReader(bundle.Bytes())); err == nil || !strings.Contains(err.Error(), `function "shout" not defined`) {
+		t.Fatal("expected undefined function error, got:", err)
+	}
+
+	delete(fsys, "partials/title.grm")
+	if _, err := New().Funcs(funcs).FS(fsys).ReadBundle(bytes.NewReader(bundle.Bytes())); err == nil || !strings.Contains(err.Error(), "stale bundle") {
+		t.Fatal("expected stale bundle error, got:", err)
+	}
+
+	stale := strings.Replace(bundle.String(), "groom bundle 1\n", "groom bundle 0\n", 1)
+	if _, err := New().ReadBundle(strings.NewReader(stale)); err == nil || !strings.Contains(err.Error(), "stale bundle") {
+		t.Fatal("expected stale bundle error, got:", err)
+	}
+}
+
+func TestRenderer4(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"index.grm":  {Data: []byte(`{{ define "title" }}Title{{ end }}{{ template "import footer" . }}`)},
+		"footer.grm": {Data: []byte(`footer`)},
+	}
+
+	buf := &bytes.Buffer{}
+	r := New().FS(fsys).Output(buf)
+	if _, err := r.ParseFile("index.grm"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, tmpl := range r.Templates() {
+		names = append(names, tmpl.Name+":"+tmpl.Path)
+	}
+	if strings.Join(names, " ") != "footer:footer.grm index.grm:index.grm title:index.grm" {
+		t.Fatalf("unexpected templates: %v", names)
+	}
+
+	if err := r.RenderTemplate("title", nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Title" {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `Title`\n", buf.String())
+	}
+
+	err := r.RenderTemplate("header", nil)
+	if err == nil || !strings.Contains(err.Error(), `"footer", "index.grm", "title"`) {
+		t.Fatal("expected error listing the templates, got:", err)
+	}
+}
+
+func TestRegexCache1(t *testing.T) {
+
+	c := &regexCache{patterns: map[string]*regexp.Regexp{}}
+	for idx := 0; idx < maxRegexPatterns*2+1; idx++ {
+		if _, err := c.compile("regexMatch", fmt.Sprintf("a{%d}", idx)); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.patterns) > maxRegexPatterns {
+			t.Fatalf("regex cache holds %d patterns, expected at most %d", len(c.patterns), maxRegexPatterns)
+		}
+	}
+}
+
+func TestPlugin1(t *testing.T) {
+
+	dir := t.TempDir()
+	hang := filepath.Join(dir, "groom-func-hang")
+	if err := ioutil.WriteFile(hang, []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(timeout time.Duration) { pluginTimeout = timeout }(pluginTimeout)
+	pluginTimeout = 100 * time.Millisecond
+	_, err := New().Plugin(hang)
+	if err == nil || !strings.Contains(err.Error(), "plugin groom-func-hang: handshake: no handshake") {
+		t.Fatal("expected handshake timeout, got:", err)
+	}
+
+	lazy := filepath.Join(dir, "groom-func-lazy")
+	script := "#!/bin/sh\ntouch \"$0.started\"\n" +
+		"echo '{\"protocol\": 1, \"functions\": [\"hi\"]}'\n" +
+		"read line\necho '{\"jsonrpc\": \"2.0\", \"id\": 1, \"result\": \"hi\"}'\n"
+	if err := ioutil.WriteFile(lazy, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(lazy+".json", []byte(`{"protocol": 1, "functions": ["hi"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	r, err := New().Output(buf).Plugin(lazy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := os.Stat(lazy + ".started"); err == nil {
+		t.Fatal("plugin with a manifest started before its first call")
+	}
+	if _, err := r.ParseText("lazy", "", `{{ hi }}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Render(nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "hi" {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `hi`\n", buf.String())
+	}
+}
+
+func TestMarkdownOptions1(t *testing.T) {
+
+	content := "# Hi\n\n| a |\n|---|\n| b |\n"
+	opts := map[string]interface{}{"gfm": false, "tables": true, "toc": true, "headingIds": false}
+	expected, err := markdownFunc(opts, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(expected.(string), "<table>") || !strings.Contains(expected.(string), "<nav>") {
+		t.Fatalf("markdown result: `%s`\ndoes not contain a table and a toc\n", expected)
+	}
+	for idx := 0; idx < 50; idx++ {
+		result, err := markdownFunc(opts, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Fatalf("markdown result: `%s`\ndoes not match expected: `%s`\n", result, expected)
+		}
+	}
+
+	mo, err := newMarkdownOptions("markdown", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mo.extensions&blackfriday.EXTENSION_AUTO_HEADER_IDS != 0 || mo.extensions&blackfriday.EXTENSION_TABLES == 0 {
+		t.Fatalf("unexpected markdown extensions: %b", mo.extensions)
+	}
+}
+
+func TestSafe1(t *testing.T) {
+
+	_, err := New().Safe(true).ParseText("safe", "", `<b>{{ . }}</b>`)
+	if err == nil || !strings.Contains(err.Error(), "safe mode is not supported") {
+		t.Fatal("expected safe mode error, got:", err)
+	}
+}
@@ -0,0 +1,282 @@
+package groom
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PluginPrefix starts the names of the plugin executables found by
+// FindPlugins, as in "groom-func-git".
+const PluginPrefix = "groom-func-"
+
+// pluginProtocol is the version of the plugin protocol.
+//
+// A plugin starts by writing a handshake line of JSON declaring its
+// functions:
+//
+//	{"protocol": 1, "functions": ["rot13", "slug"]}
+//
+// Each call of a function is then a JSON-RPC 2.0 request written to the
+// plugin's standard input as a line, with the arguments as the params:
+//
+//	{"jsonrpc": "2.0", "id": 1, "method": "rot13", "params": ["groom"]}
+//
+// and answered by a line on its standard output with the result or error:
+//
+//	{"jsonrpc": "2.0", "id": 1, "result": "tebbz"}
+//	{"jsonrpc": "2.0", "id": 1, "error": {"code": 1, "message": "..."}}
+//
+// The plugin exits when its standard input is closed.
+//
+// A manifest holding the handshake may be installed next to the plugin,
+// with the name of the plugin followed by ".json", so that the plugin is
+// only started when one of its functions is first called.
+const pluginProtocol = 1
+
+// pluginTimeout bounds the wait for the handshake of a plugin, which is
+// killed when it has not written it in time.
+var pluginTimeout = 5 * time.Second
+
+type pluginHandshake struct {
+	Protocol  int      `json:"protocol"`
+	Functions []string `json:"functions"`
+}
+
+type pluginRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type pluginResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// plugin is a plugin executable, started when first needed and called one
+// request at a time.
+type plugin struct {
+	sync.Mutex
+	name   string
+	path   string
+	err    error
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	id     int
+}
+
+// FindPlugins returns the paths of the executables in the directories
+// with names starting with PluginPrefix, or in the directories of PATH
+// when none are given. Only the first of plugins of the same name is
+// returned, as for commands on PATH.
+func FindPlugins(dirs ...string) []string {
+	if len(dirs) == 0 {
+		dirs = filepath.SplitList(os.Getenv("PATH"))
+	}
+	paths := []string{}
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, PluginPrefix+"*"))
+		sort.Strings(matches)
+		for _, path := range matches {
+			name := filepath.Base(path)
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 || strings.HasSuffix(name, ".json") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Plugin adds the functions declared by the plugin executable, replacing
+// any of the same name. The plugin is started now, or when one of its
+// functions is first called if it has a manifest, and runs until Close is
+// called.
+func (r *Renderer) Plugin(path string) (*Renderer, error) {
+	p := &plugin{name: filepath.Base(path), path: path}
+	var handshake pluginHandshake
+	buf, err := ioutil.ReadFile(path + ".json")
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(buf, &handshake); err != nil {
+			return nil, fmt.Errorf("groom: plugin %s: manifest: %s", p.name, err)
+		}
+		if handshake.Protocol != pluginProtocol {
+			return nil, fmt.Errorf("groom: plugin %s: manifest: protocol %d, expected %d", p.name, handshake.Protocol, pluginProtocol)
+		}
+	case os.IsNotExist(err):
+		if handshake, err = p.start(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("groom: plugin %s: manifest: %s", p.name, err)
+	}
+
+	r.plugins = append(r.plugins, p)
+	for _, name := range handshake.Functions {
+		r.funcs[name] = p.function(name)
+	}
+	return r, nil
+}
+
+// start starts the plugin and reads its handshake, killing the plugin
+// when the handshake is not written within pluginTimeout.
+func (p *plugin) start() (pluginHandshake, error) {
+	var handshake pluginHandshake
+	cmd := exec.Command(p.path)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return handshake, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return handshake, err
+	}
+	if err := cmd.Start(); err != nil {
+		return handshake, fmt.Errorf("groom: plugin %s: %s", p.name, err)
+	}
+	p.stdout = bufio.NewReader(stdout)
+
+	done := make(chan error, 1)
+	go func() { done <- p.read(&handshake) }()
+	select {
+	case err = <-done:
+		if err == nil && handshake.Protocol != pluginProtocol {
+			err = fmt.Errorf("protocol %d, expected %d", handshake.Protocol, pluginProtocol)
+		}
+	case <-time.After(pluginTimeout):
+		err = fmt.Errorf("no handshake within %s", pluginTimeout)
+	}
+	if err != nil {
+		// Waiting closes stdout, ending the read if it timed out.
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		return handshake, fmt.Errorf("groom: plugin %s: handshake: %s", p.name, err)
+	}
+	p.cmd, p.stdin = cmd, stdin
+	return handshake, nil
+}
+
+// Close stops the plugins started by Plugin.
+func (r *Renderer) Close() error {
+	var err error
+	for _, p := range r.plugins {
+		if perr := p.close(); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	r.plugins = nil
+	return err
+}
+
+// read reads a line of JSON from the plugin into v.
+func (p *plugin) read(v interface{}) error {
+	line, err := p.stdout.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		if err == io.EOF {
+			return errors.New("plugin exited")
+		}
+		return err
+	}
+	return json.Unmarshal(line, v)
+}
+
+func (p *plugin) function(name string) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		result, err := p.call(name, args)
+		if err != nil {
+			return nil, fmt.Errorf("groom: %s: %s", name, err)
+		}
+		return result, nil
+	}
+}
+
+func (p *plugin) call(method string, params []interface{}) (interface{}, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.cmd == nil {
+		// A plugin with a manifest is started by its first call, and
+		// not again when that failed.
+		if p.err == nil {
+			_, p.err = p.start()
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+	}
+
+	if params == nil {
+		params = []interface{}{}
+	}
+	for idx, param := range params {
+		// Byte slices, such as the output of cat, are passed as strings
+		// rather than base64 encoded.
+		if buf, ok := plainArg(param).([]byte); ok {
+			params[idx] = string(buf)
+		}
+	}
+
+	p.id++
+	buf, err := json.Marshal(pluginRequest{JSONRPC: "2.0", ID: p.id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.stdin.Write(append(buf, '\n')); err != nil {
+		return nil, fmt.Errorf("plugin %s: %s", p.name, err)
+	}
+
+	var resp pluginResponse
+	if err := p.read(&resp); err != nil {
+		return nil, fmt.Errorf("plugin %s: %s", p.name, err)
+	}
+	if resp.ID != p.id {
+		return nil, fmt.Errorf("plugin %s: response id %d, expected %d", p.name, resp.ID, p.id)
+	}
+	if resp.Error != nil {
+		return nil, errors.New(resp.Error.Message)
+	}
+	var result interface{}
+	if len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, fmt.Errorf("plugin %s: %s", p.name, err)
+		}
+	}
+	return result, nil
+}
+
+func (p *plugin) close() error {
+	p.Lock()
+	defer p.Unlock()
+	if p.cmd == nil {
+		return nil
+	}
+	p.stdin.Close()
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("groom: plugin %s: %s", p.name, err)
+	}
+	return nil
+}
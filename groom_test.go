@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"flag"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -16,7 +19,7 @@ var result = []byte(`<html>
 
 func TestMain(m *testing.M) {
 	if os.Getenv("EXEC_GROOM") == "TRUE" {
-		os.Exit(groom(os.Args[1:]))
+		os.Exit(run(os.Args[1:]))
 	}
 	flag.Parse()
 	os.Exit(m.Run())
@@ -107,7 +110,20 @@ func TestJsonFunc1(t *testing.T) {
 }
 
 func TestCatFunc1(t *testing.T) {
-	cmd := GroomCmd("--data=test/cat1.json", "test/cat1.grm")
+	cmd := GroomCmd("--data=cat1.json", "test/cat1.grm")
+
+	CompareOutput(t, cmd, result)
+}
+
+func TestCatFunc2(t *testing.T) {
+	cmd := GroomCmd("--cwd", "--data=test/cat1.json", "test/cat1.grm")
+
+	CompareOutput(t, cmd, result)
+}
+
+func TestCatFunc3(t *testing.T) {
+	cmd := GroomCmd("--data=cat1.json", "cat1.grm")
+	cmd.Dir = "test"
 
 	CompareOutput(t, cmd, result)
 }
@@ -139,3 +155,516 @@ print(greeting)
 
 	CompareOutput(t, cmd, md)
 }
+
+func TestMarkdownFunc2(t *testing.T) {
+	cmd := GroomCmd("test/md2.grm")
+
+	md := []byte(`<nav>
+<ul>
+<li><a href="#guide">Guide</a>
+<ul>
+<li><a href="#install">Install</a></li>
+<li><a href="#usage">Usage</a></li>
+</ul></li>
+</ul>
+</nav>
+<nav>
+<ul>
+<li><a href="#guide">Guide</a>
+<ul>
+<li><a href="#install">Install</a></li>
+<li><a href="#usage">Usage</a></li>
+</ul></li>
+</ul>
+</nav>
+
+<h1 id="guide">Guide</h1>
+
+<h2 id="install">Install</h2>
+
+<p>Run it<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup> -- then &quot;go&quot;.</p>
+
+<h2 id="usage">Usage</h2>
+
+<table>
+<thead>
+<tr>
+<th>Flag</th>
+<th>Meaning</th>
+</tr>
+</thead>
+
+<tbody>
+<tr>
+<td>-v</td>
+<td>verbose</td>
+</tr>
+</tbody>
+</table>
+
+<pre class="chroma"><code><span class="line"><span class="cl"><span class="nx">fmt</span><span class="p">.</span><span class="nf">Println</span><span class="p">(</span><span class="s">&#34;hi&#34;</span><span class="p">)</span><span class="w">
+</span></span></span></code></pre>
+
+<p><b>raw</b></p>
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1">Once.
+ <a class="footnote-return" href="#fnref:1"><sup>[return]</sup></a></li>
+</ol>
+</div>
+<p>x and y</p>
+
+`)
+
+	CompareOutput(t, cmd, md)
+}
+
+func TestMarkdownFunc3(t *testing.T) {
+	cmd := GroomCmd("test/md3.grm")
+
+	md := []byte(`posts/first.md: First Post (12 words)
+  summary: Groom renders templates to files.
+  h2 #getting-started Getting started
+posts/second.md: Second Post (4 words)
+  summary: A short one.
+  h1 #second Second
+b
+<h2 id="hi">Hi</h2>
+`)
+
+	CompareOutput(t, cmd, md)
+}
+
+func TestHighlightFunc1(t *testing.T) {
+	cmd := GroomCmd("test/hl1.grm")
+
+	hl := []byte(`<pre class="chroma"><code><span class="line"><span class="cl"><span class="nx">x</span><span class="w"> </span><span class="o">:=</span><span class="w"> </span><span class="mi">1</span></span></span></code></pre>
+<pre style="background-color:#fff;"><code><span style="display:flex;"><span><span style="color:#6639ba">echo</span> hi</span></span></code></pre>
+<pre class="chroma"><code><span class="line"><span class="cl"><span class="nb">print</span><span class="p">(</span><span class="s2">&#34;hi&#34;</span><span class="p">)</span>
+</span></span></code></pre>
+"\x1b[38;5;61mprint\x1b[0m\x1b[38;5;235m(\x1b[0m\x1b[38;5;23m\"hi\"\x1b[0m\x1b[38;5;235m)\x1b[0m\n"
+/* Keyword */ .chroma .k { color: #cf222e }
+`)
+
+	CompareOutput(t, cmd, hl)
+}
+
+func TestHTMLFuncs1(t *testing.T) {
+	cmd := GroomCmd("test/html1.grm")
+
+	html := []byte(`Groom renders templates & docs to files.
+<p>Groom <em>renders</em> templates &amp; <a href="/docs/" rel="nofollow">docs</a> to files.</p>
+Groom renders templates &amp; docs to files.
+<p>Groom renders templates &amp; <a href="/docs/" rel="nofollow">docs</a> to files.</p>
+<p>Groom <em>renders</em>…</p>
+<p>Short</p>
+<p>Groom <em>renders</em> templates &amp; <a href="https://example.com/docs/">docs</a> to files.</p><script>alert(1)</script>
+<img src="https://example.com/img/a.png" alt="A"><a href="https://example.com/#top">top</a> <a href="mailto:x@example.com">x</a>
+<b> & "
+`)
+
+	CompareOutput(t, cmd, html)
+}
+
+func TestDelims1(t *testing.T) {
+	cmd := GroomCmd("--delims", "<% %>", "--Greeting=Hello World", "internal/template/test/delims2.grm")
+
+	CompareOutput(t, cmd, []byte("Hello World {{ .Greeting }}\n"))
+}
+
+func TestDelims2(t *testing.T) {
+	tmpl := bytes.NewBufferString("[[ .Greeting ]] {{ .Greeting }}\n")
+	cmd, cerr := GroomStdin(tmpl, "--delims=[[ ]]", "--Greeting=Hello World")
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+
+	CompareOutput(t, cmd, []byte("Hello World {{ .Greeting }}\n"))
+}
+
+func TestBlocks1(t *testing.T) {
+	cmd := GroomCmd("--trim-blocks", "--lstrip-blocks", "test/blocks1.grm")
+
+	blocks := []byte(`services:
+  - name: web
+  - name: cache
+`)
+
+	CompareOutput(t, cmd, blocks)
+}
+
+func TestBlocks2(t *testing.T) {
+	cmd := GroomCmd("--trim-blocks", "test/blocks1.grm")
+
+	blocks := []byte("services:\n    - name: web\n        - name: cache\n  ")
+
+	CompareOutput(t, cmd, blocks)
+}
+
+func TestBundle1(t *testing.T) {
+	expected := []byte("groom says hello\nfiles: a.txt b.txt\nexists: true false\n")
+
+	cmd := GroomCmd("test/bundle1.zip", "--name=groom")
+	CompareOutput(t, cmd, expected)
+
+	cmd = GroomCmd("test/bundle1.tar", "index.grm", "--name=groom")
+	CompareOutput(t, cmd, expected)
+}
+
+func TestCompile1(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "site.grmc")
+
+	cmd := GroomCmd("compile", "-o", bundle, "test/bundle1")
+	CompareOutput(t, cmd, []byte{})
+
+	cmd = GroomCmd("run", bundle, "--name=groom")
+	CompareOutput(t, cmd, []byte("groom says hello\nfiles: a.txt b.txt\nexists: true false\n"))
+}
+
+func TestPlugin1(t *testing.T) {
+	dir := t.TempDir()
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, "groom-func-test"), "./test/plugin")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatal("Plugin build failed:\n", string(output))
+	}
+
+	cmd := GroomCmd("--plugins="+dir, "--greeting=Hello World", "test/plugin1.grm")
+	CompareOutput(t, cmd, []byte("Uryyb Jbeyq\n[a][b]\n"))
+
+	cmd, cerr := GroomStdin(bytes.NewBufferString(`{{ fail }}`), "--plugins="+dir)
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+	if _, err := cmd.Output(); err == nil {
+		t.Fatal("expected plugin function error")
+	}
+}
+
+func TestConfig1(t *testing.T) {
+	cmd := GroomCmd("page.grm")
+	cmd.Dir = "test/config1"
+	CompareOutput(t, cmd, []byte("Groom: page\n"))
+
+	cmd = GroomCmd("--title=Site", "page.grm")
+	cmd.Dir = "test/config1"
+	CompareOutput(t, cmd, []byte("Site: page\n"))
+
+	cmd = GroomCmd("build", "docs")
+	cmd.Dir = "test/config1/docs"
+	CompareOutput(t, cmd, []byte("Groom: Docs\n"))
+
+	cmd = GroomCmd("build", "--section=Build")
+	cmd.Dir = "test/config1/docs"
+	CompareOutput(t, cmd, []byte("Groom: Build\nGroom: page\n"))
+}
+
+func TestConfig2(t *testing.T) {
+	cmd := GroomCmd("blocks.grm")
+	cmd.Dir = "test/config2"
+	CompareOutput(t, cmd, []byte("blocks\n"))
+
+	cmd = GroomCmd("--no-trim-blocks", "blocks.grm")
+	cmd.Dir = "test/config2"
+	CompareOutput(t, cmd, []byte("\nblocks\n\n"))
+
+	cmd = GroomCmd("build", "-o", "out.txt")
+	cmd.Dir = "test/config1"
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("Expected usage error for --output with many targets, got: %v", err)
+	}
+}
+
+func TestArgs1(t *testing.T) {
+	cmd := GroomCmd("--set", "greeting=Hello World", "test/test1.grm")
+	CompareOutput(t, cmd, result)
+
+	cmd = GroomCmd("--set=greeting=Hello World", "--", "test/test1.grm")
+	CompareOutput(t, cmd, result)
+
+	tmpl := bytes.NewBufferString(`<html>
+    <body>{{ .greeting }}</body>
+</html>
+`)
+	cmd, cerr := GroomStdin(tmpl, "--greeting=Hello World", "-")
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+	CompareOutput(t, cmd, result)
+}
+
+func TestArgs2(t *testing.T) {
+	output, err := GroomCmd("--help").Output()
+	if err != nil || !bytes.HasPrefix(output, []byte("Usage: groom")) || !bytes.Contains(output, []byte("--trim-blocks")) {
+		t.Fatalf("Unexpected help output: %v\n%s", err, output)
+	}
+
+	output, err = GroomCmd("--version").Output()
+	if err != nil || !bytes.HasPrefix(output, []byte("groom ")) {
+		t.Fatalf("Unexpected version output: %v\n%s", err, output)
+	}
+
+	for _, args := range [][]string{
+		{"--set", "greeting", "test/test1.grm"},
+		{"test/test1.grm", "--delims"},
+	} {
+		err := GroomCmd(args...).Run()
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+			t.Fatalf("Expected usage error for %q, got: %v", args, err)
+		}
+	}
+}
+
+func TestArgs3(t *testing.T) {
+	tmpl := `{{ .draft | printf "%q" }} {{ .version }} {{ .output }}`
+	cmd, cerr := GroomStdin(bytes.NewBufferString(tmpl), "--draft", "--version=1.2", "--set", "output=x")
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	CompareOutput(t, cmd, []byte(`"" 1.2 x`))
+	if !strings.Contains(stderr.String(), "groom: deprecated: --draft sets data, use --set draft=\n") ||
+		!strings.Contains(stderr.String(), "groom: deprecated: --version=1.2 sets data, use --set version=1.2\n") {
+		t.Fatalf("Expected deprecation warnings, got:\n%s", stderr.String())
+	}
+
+	cmd, cerr = GroomStdin(bytes.NewBufferString(`{{ .outptu }}`), "--outptu=x")
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+	stderr.Reset()
+	cmd.Stderr = stderr
+	CompareOutput(t, cmd, []byte("x"))
+	if stderr.String() != "groom: deprecated: --outptu=x sets data, use --set outptu=x\n" {
+		t.Fatalf("Expected deprecation warning, got:\n%s", stderr.String())
+	}
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	cmd, cerr = GroomStdin(bytes.NewBufferString(`{{ .output }}`), "--output="+output)
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+	CompareOutput(t, cmd, []byte{})
+	if buf, err := ioutil.ReadFile(output); err != nil || string(buf) != "<no value>" {
+		t.Fatalf("Expected --output=file to write the file, got: %q %v", buf, err)
+	}
+}
+
+func TestTemplateOption1(t *testing.T) {
+	cmd := GroomCmd("--greeting=Hello World", "-t", "title", "test/tmpl2.grm")
+	CompareOutput(t, cmd, []byte("<h1>Hello World</h1>"))
+
+	cmd = GroomCmd("--list-templates", "test/tmpl2.grm")
+	CompareOutput(t, cmd, []byte(`part1      test/partial/part1.grm
+title      test/tmpl2.grm
+tmpl2.grm  test/tmpl2.grm
+`))
+
+	_, err := GroomCmd("--template=header", "test/tmpl2.grm").Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || !bytes.Contains(exitErr.Stderr, []byte(`"part1", "title", "tmpl2.grm"`)) {
+		t.Fatalf("Expected error listing the templates, got: %v", err)
+	}
+}
+
+func TestCollectionFuncs1(t *testing.T) {
+	data, oerr := os.Open("test/coll1.json")
+	if oerr != nil {
+		t.Fatal("Error reading data:", oerr)
+	}
+	defer data.Close()
+
+	cmd, cerr := GroomStdin(data, "test/coll1.grm")
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+
+	CompareOutput(t, cmd, []byte(`sorted: First Second Third
+go: Second First
+tags: go web
+keys: color=red font=serif
+missing: none
+list: 1 4 3 [2 3] [1 2 3 4 5]
+`))
+}
+
+func TestMathFuncs1(t *testing.T) {
+	data := bytes.NewBuffer([]byte(`{
+		"items": [ { "name": "a.txt", "size": 512 }, { "name": "b.bin", "size": 1536000 } ],
+		"total": 1234567, "used": 617283.5, "price": 3.14159
+	}`))
+
+	cmd, cerr := GroomStdin(data, "test/math1.grm")
+	if cerr != nil {
+		t.Fatal("Error creating command:", cerr)
+	}
+
+	CompareOutput(t, cmd, []byte(`1. a.txt 512 B
+2. b.bin 1.5 MB
+total: 2 files, 1,234,567 bytes
+ratio: 50% 3.14 3.1
+int: 7 24 3 1 1 3 2 3
+`))
+}
+
+var timeResult = []byte(`built: 2020-03-01T12:30:00Z (1583065800)
+tomorrow: Mon Mar 2
+tokyo: 21:30 JST
+parsed: Feb 29, 2020
+elapsed: 36h30m0s 1h30m0s
+`)
+
+func TestTimeFuncs1(t *testing.T) {
+	cmd := GroomCmd("test/time1.grm")
+	cmd.Env = append(cmd.Env, "SOURCE_DATE_EPOCH=1583065800")
+
+	CompareOutput(t, cmd, timeResult)
+}
+
+func TestTimeFuncs2(t *testing.T) {
+	cmd := GroomCmd("--now=2020-03-01T12:30:00Z", "test/time1.grm")
+	cmd.Env = append(cmd.Env, "SOURCE_DATE_EPOCH=0")
+
+	CompareOutput(t, cmd, timeResult)
+}
+
+func TestEncodingFuncs1(t *testing.T) {
+	cmd := GroomCmd("test/enc1.grm")
+
+	CompareOutput(t, cmd, []byte(`base64: SGVsbG8gV29ybGQ= Hello World
+hex: 6869
+url: a+b%26c%3Dd%2F%C3%A9 a b&c
+sha1: 0a4d55a8d778e5022fab701977c5d840bbc486d0
+sha256: a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e
+md5: b10a8db164e0754105b7a99be72e3fe5
+crc32: 4a17b156
+uuidv5: cfbff0d1-9375-5685-968c-48ce8b15ae17
+uuidv4: 36
+`))
+}
+
+func TestFormatFuncs1(t *testing.T) {
+	cmd := GroomCmd("test/fmt1.grm")
+
+	CompareOutput(t, cmd, []byte(`name = "web"
+replicas = 3
+
+[labels]
+  app = "web"
+  tier = "frontend"
+
+[[ports]]
+  name = "http"
+  port = 80
+
+[[ports]]
+  name = "https"
+  port = 443
+
+{
+    "app": "web",
+    "tier": "frontend"
+}
+<service>
+  <labels>
+    <app>web</app>
+    <tier>frontend</tier>
+  </labels>
+  <name>web</name>
+  <ports>
+    <name>http</name>
+    <port>80</port>
+  </ports>
+  <ports>
+    <name>https</name>
+    <port>443</port>
+  </ports>
+  <replicas>3</replicas>
+</service>
+name,port
+http,80
+https,443
+http https 
+ssh=22
+2
+a:
+  - 1
+  - 2
+`))
+}
+
+func TestRegexFuncs1(t *testing.T) {
+	cmd := GroomCmd("test/regex1.grm")
+
+	CompareOutput(t, cmd, []byte(`match: true false
+find: 1.2.3
+all: [1 2 3 2020 03 01]
+replace: version 1.2.3 released 01/03/2020
+split: [version][1.2.3][released][2020-03-01]
+quote: 1\.2\.3
+> line one
+> line two
+`))
+}
+
+func TestRegexFuncs2(t *testing.T) {
+	cmd := GroomCmd("test/regex2.grm")
+
+	_, err := cmd.Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatal("Command expected to fail:", err)
+	}
+	expected := "template: regex2.grm:1:3: executing \"regex2.grm\" at <regexMatch \"(\" \"x\">: error calling regexMatch: groom: regexMatch: invalid pattern"
+	if !bytes.HasPrefix(exitErr.Stderr, []byte(expected)) {
+		t.Fatalf("Command error comparison failed:\n%s\nExpecting:\n%s\n", exitErr.Stderr, expected)
+	}
+}
+
+func TestFsFuncs1(t *testing.T) {
+	cmd := GroomCmd("test/fs1.grm")
+
+	CompareOutput(t, cmd, []byte(`glob: fs1/a.txt fs1/b.txt
+ls: a.txt(6) b.txt(14) sub/
+walk: fs1/a.txt fs1/b.txt fs1/sub fs1/sub/c.md
+exists: true false
+stat: 14
+lines: [beta] [beta two]
+`))
+}
+
+func TestCatFuncImport1(t *testing.T) {
+	cmd := GroomCmd("test/import1.grm")
+
+	CompareOutput(t, cmd, result)
+}
+
+func TestEnvFuncs1(t *testing.T) {
+	cmd := GroomCmd("--env=GROOM_TEST_", "test/env1.grm")
+	cmd.Env = append(cmd.Env, "GROOM_TEST_HOME=/home/groom", "GROOM_TEST_TOKEN=secret")
+
+	CompareOutput(t, cmd, []byte(`home: /home/groom
+port: 8080 /home/groom
+token: secret
+expand: /home/groom/bin:secret
+data: GROOM_TEST_HOME=/home/groom GROOM_TEST_TOKEN=secret
+`))
+}
+
+func TestEnvFuncs2(t *testing.T) {
+	cmd := GroomCmd("test/env2.grm")
+
+	_, err := cmd.Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatal("Command expected to fail:", err)
+	}
+	expected := "environment variable GROOM_TEST_MISSING is not set"
+	if !bytes.Contains(exitErr.Stderr, []byte(expected)) {
+		t.Fatalf("Command error comparison failed:\n%s\nExpecting:\n%s\n", exitErr.Stderr, expected)
+	}
+}
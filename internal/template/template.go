@@ -1,14 +1,21 @@
 package template
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	htemplate "html/template"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	ttemplate "github.com/makeshiftd/groom/internal/template/text/template"
 	"github.com/makeshiftd/groom/internal/template/text/template/parse"
@@ -17,16 +24,166 @@ import (
 
 type FuncMap map[string]interface{}
 
+// Context describes the execution of the template calling a function, it
+// is passed to functions that declare it as their first parameter.
+type Context = ttemplate.Context
+
+// Mode controls the whitespace around block actions, such as if, range
+// and end, so they may be written on lines of their own.
+type Mode = parse.Mode
+
+const (
+	// TrimBlocks removes the first newline after a block action.
+	TrimBlocks = parse.TrimBlocks
+	// LstripBlocks removes the spaces and tabs before a block action at
+	// the start of a line.
+	LstripBlocks = parse.LstripBlocks
+)
+
 type Template struct {
-	safe  bool
-	tmpl  interface{}
-	funcs FuncMap
+	safe   bool
+	tmpl   interface{}
+	funcs  FuncMap
+	tpls   *tplCache
+	delims [2]string
+	mode   Mode
+	paths  []string
+	fsys   fs.FS
+}
+
+// maxTplTemplates bounds the templates held by a tplCache, which lives as
+// long as its Template, it is emptied when full.
+const maxTplTemplates = 256
+
+// tplCache holds the templates parsed by the tpl function, by directory
+// (for resolving imports) and content.
+type tplCache struct {
+	sync.Mutex
+	tmpls map[[2]string]*Template
 }
 
 var debug = debugger.Debug("groom:template")
 
-func New(funcs FuncMap, safe bool) *Template {
-	return &Template{funcs: funcs, safe: safe}
+var builtins = ttemplate.Builtins()
+
+func New(funcs FuncMap, safe bool, mode ...Mode) *Template {
+	t := &Template{funcs: FuncMap{}, safe: safe}
+	for _, m := range mode {
+		t.mode |= m
+	}
+	for name, fn := range funcs {
+		t.funcs[name] = fn
+	}
+	t.tpls = &tplCache{tmpls: map[[2]string]*Template{}}
+	t.funcs["tpl"] = t.tplFunc
+	return t
+}
+
+// Delims sets the action delimiters of the templates parsed after the call,
+// unless they begin with a line overriding the delimiters. Empty delimiters
+// are the default "{{" or "}}".
+func (t *Template) Delims(left, right string) *Template {
+	t.delims = [2]string{left, right}
+	return t
+}
+
+// SearchPath sets the directories searched for imports that are not found
+// relative to the importing template.
+func (t *Template) SearchPath(dirs ...string) *Template {
+	t.paths = dirs
+	return t
+}
+
+// FS sets the file system the templates and their imports are read from,
+// instead of the operating system's. Paths are converted to slash
+// separated paths relative to the root of the file system.
+func (t *Template) FS(fsys fs.FS) *Template {
+	t.fsys = fsys
+	return t
+}
+
+// FSPath converts a file path to a path in a file system as used by FS.
+func FSPath(p string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "/")
+}
+
+func (t *Template) open(path string) (io.ReadCloser, error) {
+	if t.fsys != nil {
+		return t.fsys.Open(FSPath(path))
+	}
+	return os.Open(path)
+}
+
+func (t *Template) exists(path string) bool {
+	var err error
+	if t.fsys != nil {
+		_, err = fs.Stat(t.fsys, FSPath(path))
+	} else {
+		_, err = os.Stat(path)
+	}
+	return err == nil
+}
+
+// DELIMS_REGEXP matches a first line setting the delimiters of the rest of
+// the file, as in "{{/* groom:delims [[ ]] */}}" or "# groom:delims <% %>".
+var DELIMS_REGEXP = regexp.MustCompile("^[^\\n]*?\\bgroom:delims[ \\t]+([^\\s]+)[ \\t]+([^\\s]+)(?:[ \\t][^\\n]*)?(?:\\n|$)")
+
+// fileDelims returns the delimiters of the text, and the text with the
+// line overriding the delimiters left empty, so the line numbers of the
+// rest are unchanged. The newline of that line is removed by trimDelims.
+func (t *Template) fileDelims(text string) (string, string, string) {
+	if match := DELIMS_REGEXP.FindStringSubmatch(text); match != nil {
+		rest := text[len(match[0]):]
+		if strings.HasSuffix(match[0], "\n") {
+			rest = "\n" + rest
+		}
+		return match[1], match[2], rest
+	}
+	return t.delims[0], t.delims[1], text
+}
+
+// trimDelims removes the newline left by fileDelims in place of the line
+// overriding the delimiters from the start of the parsed tree.
+func trimDelims(tree *parse.Tree) {
+	if tree == nil || tree.Root == nil || len(tree.Root.Nodes) == 0 {
+		return
+	}
+	if text, ok := tree.Root.Nodes[0].(*parse.TextNode); ok && text.Pos == 0 && len(text.Text) > 0 && text.Text[0] == '\n' {
+		text.Text = text.Text[1:]
+	}
+}
+
+// tplFunc parses text as a template, with the same functions and imports
+// relative to the calling template, and returns the result of executing
+// it with data. The execution counts towards the calling template's depth.
+func (t *Template) tplFunc(ctx *Context, text string, data interface{}) (string, error) {
+	path := ctx.Path()
+	if path == "" {
+		path = "tpl"
+	}
+	key := [2]string{filepath.Dir(path), text}
+
+	t.tpls.Lock()
+	tmpl, ok := t.tpls.tmpls[key]
+	if !ok {
+		var err error
+		tmpl, err = New(t.funcs, t.safe, t.mode).Delims(t.delims[0], t.delims[1]).SearchPath(t.paths...).FS(t.fsys).ParseText("tpl", path, text)
+		if err != nil {
+			t.tpls.Unlock()
+			return "", err
+		}
+		if len(t.tpls.tmpls) >= maxTplTemplates {
+			t.tpls.tmpls = map[[2]string]*Template{}
+		}
+		t.tpls.tmpls[key] = tmpl
+	}
+	t.tpls.Unlock()
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.executeContext(ctx, buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
 
 func (t *Template) ParseFile(name, path string) (*Template, error) {
@@ -35,7 +192,7 @@ func (t *Template) ParseFile(name, path string) (*Template, error) {
 
 func (t *Template) parseFileWithLevel(name, path string, level int) (*Template, error) {
 	debug("Open path: %s", path)
-	f, err := os.Open(path)
+	f, err := t.open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -59,10 +216,16 @@ func (t *Template) parseTextWithLevel(name, path, text string, level int) (tt *T
 
 	dir := filepath.Dir(path)
 
-	trees, perr := parse.Parse(name, text, "{{", "}}", t.funcs)
-	if perr != nil {
+	left, right, delimsText := t.fileDelims(text)
+	trees := map[string]*parse.Tree{}
+	tree := parse.New(name)
+	tree.Mode = t.mode
+	if _, perr := tree.Parse(delimsText, left, right, trees, t.funcs, builtins); perr != nil {
 		return nil, perr
 	}
+	if delimsText != text {
+		trimDelims(trees[name])
+	}
 
 	var stack []parse.Node
 
@@ -86,45 +249,47 @@ func (t *Template) parseTextWithLevel(name, path, text string, level int) (tt *T
 
 		for node, ok := pop(); ok; node, ok = pop() {
 			switch node := node.(type) {
+			case *parse.ActionNode:
+				if err := t.parseIncludes(node.Pipe, dir, level); err != nil {
+					return nil, err
+				}
 			case *parse.TemplateNode:
-				imp := strings.Split(node.Name, " ")
-				var name, path string
-				if len(imp) > 0 && (imp[0] == "import") {
-					if len(imp) == 3 {
-						name = imp[1]
-						path = imp[2]
-					} else if len(imp) == 2 {
-						path = imp[1]
-					} else {
-						// ERROR
-					}
-					//name, path := istmt[2], filepath.FromSlash(istmt[3])
-					if filepath.Ext(path) == "" {
-						path += ".grm"
-					}
-					if name == "" {
-						name = nameFromPath(path)
-					}
-					if !filepath.IsAbs(path) {
-						path = filepath.Join(dir, path)
-					}
-					_, err := t.parseFileWithLevel(name, path, level)
-					if err != nil {
-						return nil, err
-					}
-					node.Name = name
+				name, err := t.parseImport(node.Name, dir, level)
+				if err != nil {
+					return nil, err
+				}
+				node.Name = name
+				if err := t.parseIncludes(node.Pipe, dir, level); err != nil {
+					return nil, err
 				}
+			case *parse.IfNode:
+				push(&node.BranchNode)
+			case *parse.RangeNode:
+				push(&node.BranchNode)
+			case *parse.WithNode:
+				push(&node.BranchNode)
 			case *parse.BranchNode:
+				if err := t.parseIncludes(node.Pipe, dir, level); err != nil {
+					return nil, err
+				}
 				push(node.ElseList)
 				push(node.List)
 			case *parse.ApplyNode:
+				if err := t.parseIncludes(node.Pipe, dir, level); err != nil {
+					return nil, err
+				}
 				push(node.ElseList)
 				push(node.List)
+			case *parse.ListNode:
+				if node != nil {
+					push(node.Nodes...)
+				}
 			}
 		}
 	}
 
 	for name, tree := range trees {
+		tree.Path = path
 		_, err := t.addParseTree(name, tree)
 		if err != nil {
 			return nil, err
@@ -134,6 +299,90 @@ func (t *Template) parseTextWithLevel(name, path, text string, level int) (tt *T
 	return t.Lookup(name), nil
 }
 
+// parseImport parses the file named by an import statement of the form
+// "import [name] path" and returns the name of the imported template.
+// Any other template name is returned unchanged.
+func (t *Template) parseImport(stmt, dir string, level int) (string, error) {
+	imp := strings.Split(stmt, " ")
+	var name, path string
+	if len(imp) > 0 && (imp[0] == "import") {
+		if len(imp) == 3 {
+			name = imp[1]
+			path = imp[2]
+		} else if len(imp) == 2 {
+			path = imp[1]
+		} else {
+			// ERROR
+		}
+		//name, path := istmt[2], filepath.FromSlash(istmt[3])
+		if filepath.Ext(path) == "" {
+			path += ".grm"
+		}
+		if name == "" {
+			name = nameFromPath(path)
+		}
+		if !filepath.IsAbs(path) {
+			path = t.searchPath(dir, path)
+		}
+		_, err := t.parseFileWithLevel(name, path, level)
+		if err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	return stmt, nil
+}
+
+// searchPath returns the path relative to dir, or else the first search
+// path directory containing it. When the path is not found at all, the
+// path relative to dir is returned for the error to refer to.
+func (t *Template) searchPath(dir, path string) string {
+	for _, d := range append([]string{dir}, t.paths...) {
+		p := filepath.Join(d, path)
+		if t.exists(p) {
+			return p
+		}
+	}
+	return filepath.Join(dir, path)
+}
+
+// parseIncludes parses the imports named by calls of the include function
+// in the pipeline, as in {{ include "import layout" . }}.
+func (t *Template) parseIncludes(pipe *parse.PipeNode, dir string, level int) error {
+	if pipe == nil {
+		return nil
+	}
+	for _, cmd := range pipe.Cmds {
+		for idx, arg := range cmd.Args {
+			switch arg := arg.(type) {
+			case *parse.IdentifierNode:
+				if arg.Ident != "include" || idx+1 >= len(cmd.Args) {
+					continue
+				}
+				if str, ok := cmd.Args[idx+1].(*parse.StringNode); ok {
+					name, err := t.parseImport(str.Text, dir, level)
+					if err != nil {
+						return err
+					}
+					str.Text = name
+					str.Quoted = strconv.Quote(name)
+				}
+			case *parse.PipeNode:
+				if err := t.parseIncludes(arg, dir, level); err != nil {
+					return err
+				}
+			case *parse.ChainNode:
+				if node, ok := arg.Node.(*parse.PipeNode); ok {
+					if err := t.parseIncludes(node, dir, level); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (t *Template) Lookup(name string) *Template {
 	switch tmpl := t.tmpl.(type) {
 	case *ttemplate.Template:
@@ -141,18 +390,137 @@ func (t *Template) Lookup(name string) *Template {
 		if tmpl == nil {
 			return nil
 		}
-		return &Template{tmpl: tmpl, safe: t.safe, funcs: t.funcs}
+		return t.with(tmpl)
 	case *htemplate.Template:
 		tmpl = tmpl.Lookup(name)
 		if tmpl == nil {
 			return nil
 		}
-		return &Template{tmpl: tmpl, safe: t.safe, funcs: t.funcs}
+		return t.with(tmpl)
 	default:
 		return nil
 	}
 }
 
+// with returns a copy of t for another of its templates.
+func (t *Template) with(tmpl interface{}) *Template {
+	c := *t
+	c.tmpl = tmpl
+	return &c
+}
+
+// Templates returns the templates associated with t, including those
+// imported, sorted by name.
+func (t *Template) Templates() []*Template {
+	tmpl, ok := t.tmpl.(*ttemplate.Template)
+	if !ok {
+		return nil
+	}
+	tmpls := []*Template{}
+	for _, tt := range tmpl.Templates() {
+		if tt.Tree != nil {
+			tmpls = append(tmpls, t.with(tt))
+		}
+	}
+	sort.Slice(tmpls, func(i, j int) bool {
+		return tmpls[i].Name() < tmpls[j].Name()
+	})
+	return tmpls
+}
+
+// Path returns the path of the file the template was parsed from.
+func (t *Template) Path() string {
+	if tmpl, ok := t.tmpl.(*ttemplate.Template); ok && tmpl.Tree != nil {
+		return tmpl.Tree.Path
+	}
+	return ""
+}
+
+// EncodingVersion is the version of the encoding written by Encode.
+const EncodingVersion = parse.EncodingVersion
+
+// Encode writes the parse trees of the templates associated with t, with
+// their imports already resolved, in the form read by Decode.
+func (t *Template) Encode(w io.Writer) error {
+	trees := []*parse.Tree{}
+	for _, tmpl := range t.Templates() {
+		trees = append(trees, tmpl.tmpl.(*ttemplate.Template).Tree)
+	}
+	if len(trees) == 0 {
+		return errors.New("template: no templates to encode")
+	}
+	return parse.Encode(w, trees)
+}
+
+// Decode adds the templates written by Encode, without parsing them again,
+// and returns the named template. As when parsing, the functions called
+// must be defined.
+func (t *Template) Decode(r io.Reader, name string) (*Template, error) {
+	trees, err := parse.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, tree := range trees {
+		names := map[string]bool{}
+		functions(tree.Root, names)
+		for name := range names {
+			if _, ok := t.funcs[name]; !ok && builtins[name] == nil {
+				return nil, fmt.Errorf("template: decode: %s: function %q not defined", tree.Name, name)
+			}
+		}
+	}
+	for _, tree := range trees {
+		if _, err := t.addParseTree(tree.Name, tree); err != nil {
+			return nil, err
+		}
+	}
+	return t.Lookup(name), nil
+}
+
+// functions adds the names of the functions called within the node.
+func functions(node parse.Node, names map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node != nil {
+			for _, n := range node.Nodes {
+				functions(n, names)
+			}
+		}
+	case *parse.ActionNode:
+		functions(node.Pipe, names)
+	case *parse.PipeNode:
+		if node != nil {
+			for _, cmd := range node.Cmds {
+				functions(cmd, names)
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			functions(arg, names)
+		}
+	case *parse.ChainNode:
+		functions(node.Node, names)
+	case *parse.IdentifierNode:
+		names[node.Ident] = true
+	case *parse.IfNode:
+		functions(&node.BranchNode, names)
+	case *parse.RangeNode:
+		functions(&node.BranchNode, names)
+	case *parse.WithNode:
+		functions(&node.BranchNode, names)
+	case *parse.BranchNode:
+		functions(node.Pipe, names)
+		functions(node.List, names)
+		functions(node.ElseList, names)
+	case *parse.ApplyNode:
+		functions(node.Pipe, names)
+		functions(node.List, names)
+		functions(node.ElseList, names)
+	case *parse.TemplateNode:
+		functions(node.Pipe, names)
+	}
+}
+
 func (t *Template) Name() string {
 	switch tmpl := t.tmpl.(type) {
 	case *ttemplate.Template:
@@ -175,6 +543,16 @@ func (t *Template) Execute(w io.Writer, data interface{}) error {
 	}
 }
 
+// executeContext executes t from within the execution of another template.
+func (t *Template) executeContext(ctx *Context, w io.Writer, data interface{}) error {
+	switch tmpl := t.tmpl.(type) {
+	case *ttemplate.Template:
+		return ctx.Execute(w, tmpl, data)
+	default:
+		return nil
+	}
+}
+
 func (t *Template) addParseTree(name string, tree *parse.Tree) (*Template, error) {
 	switch tmpl := t.tmpl.(type) {
 	case *ttemplate.Template:
@@ -183,7 +561,7 @@ func (t *Template) addParseTree(name string, tree *parse.Tree) (*Template, error
 		if err != nil {
 			return nil, err
 		}
-		return &Template{tmpl: tt, safe: t.safe, funcs: t.funcs}, nil
+		return t.with(tt), nil
 	// case *htemplate.Template:
 	// 	debug("Parse:", name)
 	// 	tt, err := tmpl.New(name).AddParseTree(name, tree)
@@ -200,6 +578,7 @@ func (t *Template) addParseTree(name string, tree *parse.Tree) (*Template, error
 			// 	return nil, err
 			// }
 			// t.tmpl = tmpl
+			return nil, errors.New("template: safe mode is not supported")
 		} else {
 			debug("New:", name)
 			funcs := ttemplate.FuncMap(t.funcs)
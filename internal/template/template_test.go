@@ -2,8 +2,11 @@ package template
 
 import (
 	"bytes"
+	"io/ioutil"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestNameFromPath(t *testing.T) {
@@ -129,3 +132,236 @@ func TestTemplate3(t *testing.T) {
 		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", buf.String(), result)
 	}
 }
+
+func TestInclude1(t *testing.T) {
+
+	funcs := FuncMap{
+		"upper": strings.ToUpper,
+	}
+
+	tmpl, err := New(funcs, false).ParseFile("include1", "./test/include1.grm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"Greeting": "Hello World",
+		"Items":    []string{"one", "two"},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := `<ul>
+    <LI>ONE</LI>
+    <LI>TWO</LI>
+</ul>
+HELLO WORLD
+`
+	if bytes.Compare(buf.Bytes(), []byte(result)) != 0 {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", buf.String(), result)
+	}
+}
+
+func TestInclude2(t *testing.T) {
+
+	tmpl, err := New(nil, false).ParseText("include2", "include2.grm", `{{ define "loop" }}{{ include "loop" . }}{{ end }}{{ include "loop" . }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tmpl.Execute(ioutil.Discard, nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeded maximum template depth") {
+		t.Fatal("expected maximum template depth error, got:", err)
+	}
+}
+
+func TestTpl1(t *testing.T) {
+
+	tmpl, err := New(nil, false).ParseFile("tpl1", "./test/tpl1.grm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"Greeting": "Hello World",
+		"Host":     "example.com",
+		"Endpoints": []map[string]string{
+			{"URL": "https://{{ .Host }}/api"},
+			{"URL": "https://{{ .Host }}/auth"},
+			{"URL": "https://{{ .Host }}/api"},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := `
+https://example.com/api
+https://example.com/auth
+https://example.com/api
+Hello World!
+`
+	if bytes.Compare(buf.Bytes(), []byte(result)) != 0 {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", buf.String(), result)
+	}
+	if len(tmpl.tpls.tmpls) != 3 {
+		t.Fatalf("expected 3 cached templates, found %d", len(tmpl.tpls.tmpls))
+	}
+}
+
+func TestDelims1(t *testing.T) {
+
+	tmpl, err := New(nil, false).ParseFile("delims1", "./test/delims1.grm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]string{
+		"Greeting": "Hello World",
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := `name: Hello World
+on: ${{ github.event_name }}
+Hello World!
+`
+	if bytes.Compare(buf.Bytes(), []byte(result)) != 0 {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", buf.String(), result)
+	}
+}
+
+func TestFS1(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"site/index.grm":      {Data: []byte(`<p>{{ template "import ../lib/greeting" . }}</p>` + "\n")},
+		"lib/greeting.grm":    {Data: []byte(`{{- template "import name" . }}, {{ .Greeting }}`)},
+		"lib/name.grm":        {Data: []byte(`{{- .Name -}}`)},
+		"shared/footer.grm":   {Data: []byte(`footer`)},
+		"site/unused/lib.grm": {Data: []byte(`unused`)},
+	}
+
+	tmpl, err := New(nil, false).FS(fsys).SearchPath("shared").ParseText("fs1", "site/fs1.grm",
+		`{{ template "import index" . }}{{ template "import footer" . }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]string{
+		"Greeting": "Hello World",
+		"Name":     "Groom",
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	result := "<p>Groom, Hello World</p>\nfooter"
+	if bytes.Compare(buf.Bytes(), []byte(result)) != 0 {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", buf.String(), result)
+	}
+
+	if _, err := New(nil, false).FS(fsys).ParseFile("missing", "site/missing.grm"); err == nil {
+		t.Fatal("expected error parsing file missing from the file system")
+	}
+}
+
+func TestEncode1(t *testing.T) {
+
+	tmpl, err := New(nil, false).ParseFile("tpl1", "./test/tpl1.grm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := New(nil, false).Decode(buf, "tpl1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded == nil {
+		t.Fatal("expected decoded template tpl1")
+	}
+
+	names := []string{}
+	for _, tt := range decoded.Templates() {
+		names = append(names, tt.Name()+":"+tt.Path())
+	}
+	if len(names) != len(tmpl.Templates()) {
+		t.Fatalf("decoded templates %v, expected %d", names, len(tmpl.Templates()))
+	}
+
+	data := map[string]interface{}{
+		"Greeting":  "Hello World",
+		"Host":      "example.com",
+		"Endpoints": []map[string]string{{"URL": "https://{{ .Host }}/api"}},
+	}
+
+	expected := &bytes.Buffer{}
+	if err := tmpl.Execute(expected, data); err != nil {
+		t.Fatal(err)
+	}
+	result := &bytes.Buffer{}
+	if err := decoded.Execute(result, data); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(result.Bytes(), expected.Bytes()) != 0 {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `%s`\n", result.String(), expected.String())
+	}
+}
+
+func TestDelims3(t *testing.T) {
+
+	_, err := New(nil, false).ParseText("delims3", "delims3.grm", "{{/* groom:delims [[ ]] */}}\nline2\n[[ .x | nosuch ]]")
+	if err == nil || !strings.Contains(err.Error(), "delims3:3:") {
+		t.Fatal("expected parse error on line 3, got:", err)
+	}
+
+	tmpl, err := New(nil, false).ParseText("delims3", "delims3.grm", "{{/* groom:delims [[ ]] */}}\nline2\n[[ index .x 5 ]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tmpl.Execute(ioutil.Discard, map[string][]int{"x": {}})
+	if err == nil || !strings.Contains(err.Error(), "delims3:3:") {
+		t.Fatal("expected execution error on line 3, got:", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, map[string][]int{"x": {0, 1, 2, 3, 4, 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "line2\n5" {
+		t.Fatalf("template result: `%s`\ndoes not match expected: `line2\n5`\n", buf.String())
+	}
+}
+
+func TestTpl2(t *testing.T) {
+
+	tmpl, err := New(nil, false).ParseText("tpl2", "tpl2.grm", `{{ range . }}{{ tpl . nil }}{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []string{}
+	for idx := 0; idx < maxTplTemplates*2+1; idx++ {
+		items = append(items, strconv.Itoa(idx))
+	}
+	if err := tmpl.Execute(ioutil.Discard, items); err != nil {
+		t.Fatal(err)
+	}
+	if len(tmpl.tpls.tmpls) > maxTplTemplates {
+		t.Fatalf("expected at most %d cached templates, found %d", maxTplTemplates, len(tmpl.tpls.tmpls))
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package template
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Context describes the template execution that invoked a function.
+// A function whose first parameter has type *Context is passed the
+// context of the calling template. The parameter is supplied by the
+// executor and is not counted among the arguments given in the template.
+// A Context is only valid for the duration of the function call.
+type Context struct {
+	s *state
+}
+
+var contextType = reflect.TypeOf((*Context)(nil))
+
+// Name returns the name of the executing template.
+func (c *Context) Name() string {
+	return c.s.tmpl.Name()
+}
+
+// Path returns the source path of the executing template, or the empty
+// string if it is not known. For a template invoked with {{template}}
+// this is the path of the file that defined it.
+func (c *Context) Path() string {
+	if c.s.tmpl.Tree == nil {
+		return ""
+	}
+	return c.s.tmpl.Tree.Path
+}
+
+// ExecuteTemplate applies the template associated with the executing
+// template that has the given name to data, writing the output to wr.
+// The invocation counts towards the maximum template depth, as for a
+// {{template}} action.
+func (c *Context) ExecuteTemplate(wr io.Writer, name string, data interface{}) error {
+	tmpl := c.s.tmpl.tmpl[name]
+	if tmpl == nil {
+		return fmt.Errorf("template %q not defined", name)
+	}
+	return c.execute(wr, tmpl, data)
+}
+
+// Execute applies tmpl, which need not be associated with the executing
+// template, to data, writing the output to wr. The invocation counts
+// towards the maximum template depth of the executing template.
+func (c *Context) Execute(wr io.Writer, tmpl *Template, data interface{}) error {
+	return c.execute(wr, tmpl, data)
+}
+
+func (c *Context) execute(wr io.Writer, tmpl *Template, data interface{}) (err error) {
+	defer errRecover(&err)
+	if c.s.depth == maxExecDepth {
+		return fmt.Errorf("exceeded maximum template depth (%v)", maxExecDepth)
+	}
+	value, ok := data.(reflect.Value)
+	if !ok {
+		value = reflect.ValueOf(data)
+	}
+	state := &state{
+		tmpl:  tmpl,
+		wr:    wr,
+		vars:  []variable{{"$", value}},
+		depth: c.s.depth + 1,
+	}
+	if tmpl.Tree == nil || tmpl.Root == nil {
+		state.errorf("%q is an incomplete or empty template", tmpl.Name())
+	}
+	state.walk(value, tmpl.Root)
+	return
+}
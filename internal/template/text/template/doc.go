@@ -74,6 +74,12 @@ data, defined in detail in the corresponding sections that follow.
 //		delimiters, as shown here.
 /*
 
+	{{raw}} T1 {{endraw}}
+		T1 is copied to the output verbatim, without being scanned
+		for actions, so it may contain delimiters and invalid or
+		unbalanced actions. Trim markers may be used in both
+		actions. Raw blocks do not nest.
+
 	{{pipeline}}
 		The default textual representation (the same as would be
 		printed by fmt.Print) of the value of the pipeline is copied
@@ -317,6 +323,11 @@ Predefined global functions are named as follows.
 		Returns the escaped HTML equivalent of the textual
 		representation of its arguments. This function is unavailable
 		in html/template, with a few exceptions.
+	include
+		Returns the output of executing the named template with the
+		second argument as dot. Thus "include `name` .X" is the
+		output of {{template "name" .X}} as a string, which can be
+		used in a pipeline.
 	index
 		Returns the result of indexing its first argument by the
 		following arguments. Thus "index x 1 2 3" is, in Go syntax,
@@ -645,35 +645,45 @@ func (s *state) evalCall(dot, fun reflect.Value, node parse.Node, name string, a
 		args = args[1:] // Zeroth arg is function name/node; not passed to function.
 	}
 	typ := fun.Type()
+	// A leading *Context parameter is supplied by the executor, not the template.
+	numCtx := 0
+	if typ.NumIn() > 0 && typ.In(0) == contextType {
+		numCtx = 1
+	}
+	numParams := typ.NumIn() - numCtx
 	numIn := len(args)
 	if final.IsValid() {
 		numIn++
 	}
 	numFixed := len(args)
 	if typ.IsVariadic() {
-		numFixed = typ.NumIn() - 1 // last arg is the variadic one.
+		numFixed = numParams - 1 // last arg is the variadic one.
 		if numIn < numFixed {
-			s.errorf("wrong number of args for %s: want at least %d got %d", name, typ.NumIn()-1, len(args))
+			s.errorf("wrong number of args for %s: want at least %d got %d", name, numParams-1, len(args))
 		}
-	} else if numIn != typ.NumIn() {
-		s.errorf("wrong number of args for %s: want %d got %d", name, typ.NumIn(), len(args))
+	} else if numIn != numParams {
+		s.errorf("wrong number of args for %s: want %d got %d", name, numParams, len(args))
 	}
 	if !goodFunc(typ) {
 		// TODO: This could still be a confusing error; maybe goodFunc should provide info.
 		s.errorf("can't call method/function %q with %d results", name, typ.NumOut())
 	}
 	// Build the arg list.
-	argv := make([]reflect.Value, numIn)
+	argv := make([]reflect.Value, numCtx+numIn)
+	if numCtx > 0 {
+		argv[0] = reflect.ValueOf(&Context{s: s})
+	}
+	in := argv[numCtx:]
 	// Args must be evaluated. Fixed args first.
 	i := 0
 	for ; i < numFixed && i < len(args); i++ {
-		argv[i] = s.evalArg(dot, typ.In(i), args[i])
+		in[i] = s.evalArg(dot, typ.In(numCtx+i), args[i])
 	}
 	// Now the ... args.
 	if typ.IsVariadic() {
 		argType := typ.In(typ.NumIn() - 1).Elem() // Argument is a slice.
 		for ; i < len(args); i++ {
-			argv[i] = s.evalArg(dot, argType, args[i])
+			in[i] = s.evalArg(dot, argType, args[i])
 		}
 	}
 	// Add final value if necessary.
@@ -683,19 +693,24 @@ func (s *state) evalCall(dot, fun reflect.Value, node parse.Node, name string, a
 			if numIn-1 < numFixed {
 				// The added final argument corresponds to a fixed parameter of the function.
 				// Validate against the type of the actual parameter.
-				t = typ.In(numIn - 1)
+				t = typ.In(numCtx + numIn - 1)
 			} else {
 				// The added final argument corresponds to the variadic part.
 				// Validate against the type of the elements of the variadic slice.
 				t = t.Elem()
 			}
 		}
-		argv[i] = s.validateType(final, t)
+		in[i] = s.validateType(final, t)
 	}
 	result := fun.Call(argv)
 	// If we have an error that is not nil, stop execution and return that error to the caller.
 	if len(result) == 2 && !result[1].IsNil() {
 		s.at(node)
+		// Errors from executing other templates, such as by include,
+		// already describe where they occurred.
+		if err, ok := result[1].Interface().(ExecError); ok {
+			panic(err)
+		}
 		s.errorf("error calling %s: %s", name, result[1].Interface().(error))
 	}
 	v := result[0]
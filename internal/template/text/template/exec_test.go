@@ -1418,3 +1418,24 @@ func TestInterfaceValues(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteContext(t *testing.T) {
+	funcs := FuncMap{
+		"where": func(c *Context, prefix string) string {
+			return prefix + c.Name() + ":" + c.Path()
+		},
+		"count": func(c *Context, args ...int) int {
+			return len(args)
+		},
+	}
+	tmpl := Must(New("top").Funcs(funcs).Parse(`{{where "in "}} {{count 1 2 3}} {{3 | count 1}} {{template "sub"}}{{define "sub"}} {{"in " | where}}{{end}}`))
+	tmpl.Tree.Path = "top.tmpl"
+	tmpl.Lookup("sub").Tree.Path = "sub.tmpl"
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "in top:top.tmpl 3 2  in sub:sub.tmpl"; buf.String() != want {
+		t.Errorf("template output = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,371 @@
+// Encoding of parse trees, so templates can be executed without being
+// lexed and parsed again.
+
+package parse
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// EncodingVersion is the version of the encoding written by Encode. It
+// changes whenever the nodes or their encoding change, and Decode only
+// reads trees of the same version.
+const EncodingVersion = 1
+
+// encodedTrees is the encoded form of a set of trees. The texts the trees
+// were parsed from are shared by the trees of the same file and are kept
+// for the locations in execution errors.
+type encodedTrees struct {
+	Version int
+	Texts   []string
+	Trees   []encodedTree
+}
+
+type encodedTree struct {
+	Name      string
+	ParseName string
+	Path      string
+	Mode      Mode
+	Text      int
+	Root      *encodedNode
+}
+
+// encodedNode holds any type of node, with only the fields of its type set.
+type encodedNode struct {
+	Type     NodeType
+	Pos      Pos
+	Line     int
+	Text     string   // Text, String, Number and Template nodes.
+	Quoted   string   // String nodes.
+	Ident    []string // Identifier, Variable, Field and Chain nodes.
+	True     bool     // Bool nodes.
+	Number   NumberNode
+	Nodes    []*encodedNode // List nodes, Command arguments and Pipe commands.
+	Decl     []*encodedNode
+	Node     *encodedNode // Chain nodes.
+	Pipe     *encodedNode
+	List     *encodedNode
+	ElseList *encodedNode
+}
+
+// Encode writes the trees to w in a form read by Decode.
+func Encode(w io.Writer, trees []*Tree) error {
+	enc := encodedTrees{Version: EncodingVersion}
+	texts := map[string]int{}
+	for _, t := range trees {
+		idx, ok := texts[t.text]
+		if !ok {
+			idx = len(enc.Texts)
+			texts[t.text] = idx
+			enc.Texts = append(enc.Texts, t.text)
+		}
+		root, err := encodeNode(t.Root)
+		if err != nil {
+			return fmt.Errorf("template: %s: %s", t.Name, err)
+		}
+		enc.Trees = append(enc.Trees, encodedTree{
+			Name:      t.Name,
+			ParseName: t.ParseName,
+			Path:      t.Path,
+			Mode:      t.Mode,
+			Text:      idx,
+			Root:      root,
+		})
+	}
+	return gob.NewEncoder(w).Encode(&enc)
+}
+
+func encodeNodes(nodes []Node) ([]*encodedNode, error) {
+	if nodes == nil {
+		return nil, nil
+	}
+	enc := make([]*encodedNode, len(nodes))
+	for idx, node := range nodes {
+		var err error
+		if enc[idx], err = encodeNode(node); err != nil {
+			return nil, err
+		}
+	}
+	return enc, nil
+}
+
+func encodeNode(node Node) (*encodedNode, error) {
+	var err error
+	switch node := node.(type) {
+	case *ListNode:
+		if node == nil {
+			return nil, nil
+		}
+		enc := &encodedNode{Type: NodeList, Pos: node.Pos}
+		enc.Nodes, err = encodeNodes(node.Nodes)
+		return enc, err
+	case *TextNode:
+		return &encodedNode{Type: NodeText, Pos: node.Pos, Text: string(node.Text)}, nil
+	case *ActionNode:
+		enc := &encodedNode{Type: NodeAction, Pos: node.Pos, Line: node.Line}
+		enc.Pipe, err = encodeNode(node.Pipe)
+		return enc, err
+	case *PipeNode:
+		if node == nil {
+			return nil, nil
+		}
+		enc := &encodedNode{Type: NodePipe, Pos: node.Pos, Line: node.Line}
+		for _, decl := range node.Decl {
+			d, err := encodeNode(decl)
+			if err != nil {
+				return nil, err
+			}
+			enc.Decl = append(enc.Decl, d)
+		}
+		for _, cmd := range node.Cmds {
+			c, err := encodeNode(cmd)
+			if err != nil {
+				return nil, err
+			}
+			enc.Nodes = append(enc.Nodes, c)
+		}
+		return enc, nil
+	case *CommandNode:
+		enc := &encodedNode{Type: NodeCommand, Pos: node.Pos}
+		enc.Nodes, err = encodeNodes(node.Args)
+		return enc, err
+	case *IdentifierNode:
+		return &encodedNode{Type: NodeIdentifier, Pos: node.Pos, Ident: []string{node.Ident}}, nil
+	case *VariableNode:
+		return &encodedNode{Type: NodeVariable, Pos: node.Pos, Ident: node.Ident}, nil
+	case *FieldNode:
+		return &encodedNode{Type: NodeField, Pos: node.Pos, Ident: node.Ident}, nil
+	case *ChainNode:
+		enc := &encodedNode{Type: NodeChain, Pos: node.Pos, Ident: node.Field}
+		enc.Node, err = encodeNode(node.Node)
+		return enc, err
+	case *DotNode:
+		return &encodedNode{Type: NodeDot, Pos: node.Pos}, nil
+	case *NilNode:
+		return &encodedNode{Type: NodeNil, Pos: node.Pos}, nil
+	case *BoolNode:
+		return &encodedNode{Type: NodeBool, Pos: node.Pos, True: node.True}, nil
+	case *NumberNode:
+		return &encodedNode{Type: NodeNumber, Pos: node.Pos, Number: *node}, nil
+	case *StringNode:
+		return &encodedNode{Type: NodeString, Pos: node.Pos, Text: node.Text, Quoted: node.Quoted}, nil
+	case *IfNode:
+		return encodeBranch(&node.BranchNode)
+	case *RangeNode:
+		return encodeBranch(&node.BranchNode)
+	case *WithNode:
+		return encodeBranch(&node.BranchNode)
+	case *ApplyNode:
+		return encodeBranch(&BranchNode{NodeType: NodeApply, Pos: node.Pos, Line: node.Line, Pipe: node.Pipe, List: node.List, ElseList: node.ElseList})
+	case *TemplateNode:
+		enc := &encodedNode{Type: NodeTemplate, Pos: node.Pos, Line: node.Line, Text: node.Name}
+		enc.Pipe, err = encodeNode(node.Pipe)
+		return enc, err
+	}
+	return nil, fmt.Errorf("cannot encode node: %s", node)
+}
+
+func encodeBranch(node *BranchNode) (*encodedNode, error) {
+	enc := &encodedNode{Type: node.NodeType, Pos: node.Pos, Line: node.Line}
+	var err error
+	if enc.Pipe, err = encodeNode(node.Pipe); err != nil {
+		return nil, err
+	}
+	if enc.List, err = encodeNode(node.List); err != nil {
+		return nil, err
+	}
+	if enc.ElseList, err = encodeNode(node.ElseList); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// Decode reads trees written by Encode.
+func Decode(r io.Reader) ([]*Tree, error) {
+	var enc encodedTrees
+	if err := gob.NewDecoder(r).Decode(&enc); err != nil {
+		return nil, fmt.Errorf("template: decode: %s", err)
+	}
+	if enc.Version != EncodingVersion {
+		return nil, fmt.Errorf("template: decode: encoding version %d, expected %d", enc.Version, EncodingVersion)
+	}
+	trees := make([]*Tree, len(enc.Trees))
+	for idx, et := range enc.Trees {
+		if et.Text < 0 || et.Text >= len(enc.Texts) {
+			return nil, fmt.Errorf("template: decode: %s: invalid text", et.Name)
+		}
+		t := &Tree{
+			Name:      et.Name,
+			ParseName: et.ParseName,
+			Path:      et.Path,
+			Mode:      et.Mode,
+			text:      enc.Texts[et.Text],
+		}
+		root, err := t.decodeNode(et.Root)
+		if err != nil {
+			return nil, fmt.Errorf("template: decode: %s: %s", et.Name, err)
+		}
+		list, ok := root.(*ListNode)
+		if !ok {
+			return nil, fmt.Errorf("template: decode: %s: invalid root", et.Name)
+		}
+		t.Root = list
+		trees[idx] = t
+	}
+	return trees, nil
+}
+
+func (t *Tree) decodeList(enc *encodedNode) (*ListNode, error) {
+	if enc == nil {
+		return nil, nil
+	}
+	node, err := t.decodeNode(enc)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := node.(*ListNode)
+	if !ok {
+		return nil, fmt.Errorf("expected list, found %s", node)
+	}
+	return list, nil
+}
+
+func (t *Tree) decodePipe(enc *encodedNode) (*PipeNode, error) {
+	if enc == nil {
+		return nil, nil
+	}
+	node, err := t.decodeNode(enc)
+	if err != nil {
+		return nil, err
+	}
+	pipe, ok := node.(*PipeNode)
+	if !ok {
+		return nil, fmt.Errorf("expected pipeline, found %s", node)
+	}
+	return pipe, nil
+}
+
+func (t *Tree) decodeNode(enc *encodedNode) (Node, error) {
+	if enc == nil {
+		return nil, fmt.Errorf("missing node")
+	}
+	switch enc.Type {
+	case NodeList:
+		list := t.newList(enc.Pos)
+		for _, n := range enc.Nodes {
+			node, err := t.decodeNode(n)
+			if err != nil {
+				return nil, err
+			}
+			list.append(node)
+		}
+		return list, nil
+	case NodeText:
+		return t.newText(enc.Pos, enc.Text), nil
+	case NodeAction:
+		pipe, err := t.decodePipe(enc.Pipe)
+		if err != nil {
+			return nil, err
+		}
+		return t.newAction(enc.Pos, enc.Line, pipe), nil
+	case NodePipe:
+		var decl []*VariableNode
+		for _, d := range enc.Decl {
+			node, err := t.decodeNode(d)
+			if err != nil {
+				return nil, err
+			}
+			v, ok := node.(*VariableNode)
+			if !ok {
+				return nil, fmt.Errorf("expected variable, found %s", node)
+			}
+			decl = append(decl, v)
+		}
+		pipe := t.newPipeline(enc.Pos, enc.Line, decl)
+		for _, c := range enc.Nodes {
+			node, err := t.decodeNode(c)
+			if err != nil {
+				return nil, err
+			}
+			cmd, ok := node.(*CommandNode)
+			if !ok {
+				return nil, fmt.Errorf("expected command, found %s", node)
+			}
+			pipe.append(cmd)
+		}
+		return pipe, nil
+	case NodeCommand:
+		cmd := t.newCommand(enc.Pos)
+		for _, a := range enc.Nodes {
+			node, err := t.decodeNode(a)
+			if err != nil {
+				return nil, err
+			}
+			cmd.append(node)
+		}
+		return cmd, nil
+	case NodeIdentifier:
+		if len(enc.Ident) != 1 {
+			return nil, fmt.Errorf("invalid identifier")
+		}
+		return NewIdentifier(enc.Ident[0]).SetTree(t).SetPos(enc.Pos), nil
+	case NodeVariable:
+		return &VariableNode{tr: t, NodeType: NodeVariable, Pos: enc.Pos, Ident: enc.Ident}, nil
+	case NodeField:
+		return &FieldNode{tr: t, NodeType: NodeField, Pos: enc.Pos, Ident: enc.Ident}, nil
+	case NodeChain:
+		node, err := t.decodeNode(enc.Node)
+		if err != nil {
+			return nil, err
+		}
+		chain := t.newChain(enc.Pos, node)
+		chain.Field = enc.Ident
+		return chain, nil
+	case NodeDot:
+		return t.newDot(enc.Pos), nil
+	case NodeNil:
+		return t.newNil(enc.Pos), nil
+	case NodeBool:
+		return t.newBool(enc.Pos, enc.True), nil
+	case NodeNumber:
+		number := enc.Number
+		number.tr = t
+		number.NodeType = NodeNumber
+		number.Pos = enc.Pos
+		return &number, nil
+	case NodeString:
+		return t.newString(enc.Pos, enc.Quoted, enc.Text), nil
+	case NodeIf, NodeRange, NodeWith, NodeApply:
+		pipe, err := t.decodePipe(enc.Pipe)
+		if err != nil {
+			return nil, err
+		}
+		list, err := t.decodeList(enc.List)
+		if err != nil {
+			return nil, err
+		}
+		elseList, err := t.decodeList(enc.ElseList)
+		if err != nil {
+			return nil, err
+		}
+		switch enc.Type {
+		case NodeIf:
+			return t.newIf(enc.Pos, enc.Line, pipe, list, elseList), nil
+		case NodeRange:
+			return t.newRange(enc.Pos, enc.Line, pipe, list, elseList), nil
+		case NodeWith:
+			return t.newWith(enc.Pos, enc.Line, pipe, list, elseList), nil
+		default:
+			return t.newApply(enc.Pos, enc.Line, pipe, list, elseList), nil
+		}
+	case NodeTemplate:
+		pipe, err := t.decodePipe(enc.Pipe)
+		if err != nil {
+			return nil, err
+		}
+		return t.newTemplate(enc.Pos, enc.Line, enc.Text, pipe), nil
+	}
+	return nil, fmt.Errorf("invalid node type: %d", enc.Type)
+}
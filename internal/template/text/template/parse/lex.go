@@ -103,6 +103,33 @@ const (
 	trimMarkerLen   = Pos(len(leftTrimMarker))
 )
 
+// Mode controls how the text around actions is scanned.
+type Mode uint
+
+const (
+	// TrimBlocks removes the first newline after a block action, that is
+	// a comment or an action starting with a keyword such as if, range,
+	// else or end, which produce no output of their own.
+	TrimBlocks Mode = 1 << iota
+	// LstripBlocks removes the spaces and tabs before a block action at
+	// the start of a line.
+	LstripBlocks
+)
+
+// blockKeywords are the keywords starting a block action.
+var blockKeywords = map[string]bool{
+	"apply":  true,
+	"block":  true,
+	"define": true,
+	"else":   true,
+	"end":    true,
+	"endraw": true,
+	"if":     true,
+	"range":  true,
+	"raw":    true,
+	"with":   true,
+}
+
 // stateFn represents the state of the scanner as a function that returns the next state.
 type stateFn func(*lexer) stateFn
 
@@ -120,6 +147,8 @@ type lexer struct {
 	items      chan item // channel of scanned items
 	parenDepth int       // nesting depth of ( ) exprs
 	line       int       // 1+number of newlines seen
+	mode       Mode      // whitespace control around block actions
+	block      bool      // whether the current action is a block action
 }
 
 // next returns the next rune in the input.
@@ -208,7 +237,7 @@ func (l *lexer) drain() {
 }
 
 // lex creates a new scanner for the input string.
-func lex(name, input, left, right string) *lexer {
+func lex(name, input, left, right string, mode Mode) *lexer {
 	if left == "" {
 		left = leftDelim
 	}
@@ -222,6 +251,7 @@ func lex(name, input, left, right string) *lexer {
 		rightDelim: right,
 		items:      make(chan item),
 		line:       1,
+		mode:       mode,
 	}
 	go l.run()
 	return l
@@ -238,10 +268,12 @@ func (l *lexer) run() {
 // state functions
 
 const (
-	leftDelim    = "{{"
-	rightDelim   = "}}"
-	leftComment  = "/*"
-	rightComment = "*/"
+	leftDelim     = "{{"
+	rightDelim    = "}}"
+	leftComment   = "/*"
+	rightComment  = "*/"
+	rawKeyword    = "raw"
+	endRawKeyword = "endraw"
 )
 
 // lexText scans until an opening action delimiter, "{{".
@@ -253,6 +285,8 @@ func lexText(l *lexer) stateFn {
 		trimLength := Pos(0)
 		if strings.HasPrefix(l.input[l.pos+ldn:], leftTrimMarker) {
 			trimLength = rightTrimLength(l.input[l.start:l.pos])
+		} else if l.mode&LstripBlocks != 0 && l.atBlockAction(l.input[l.pos:]) {
+			trimLength = l.indentLength()
 		}
 		l.pos -= trimLength
 		if l.pos > l.start {
@@ -272,6 +306,52 @@ func lexText(l *lexer) stateFn {
 	return nil
 }
 
+// atBlockAction reports whether s begins with a block action.
+func (l *lexer) atBlockAction(s string) bool {
+	s = strings.TrimPrefix(s[len(l.leftDelim):], leftTrimMarker)
+	s = strings.TrimLeft(s, " \t")
+	if strings.HasPrefix(s, leftComment) {
+		return true
+	}
+	end := strings.IndexFunc(s, func(r rune) bool { return !isAlphaNumeric(r) })
+	if end < 0 {
+		end = len(s)
+	}
+	return blockKeywords[s[:end]]
+}
+
+// indentLength returns the length of the spaces and tabs between the start
+// of the line and the current position, or zero if there is other text or
+// an action between them.
+func (l *lexer) indentLength() Pos {
+	s := l.input[l.start:l.pos]
+	i := strings.LastIndexByte(s, '\n')
+	if i < 0 && l.start > 0 && l.input[l.start-1] != '\n' {
+		return 0
+	}
+	indent := s[i+1:]
+	if strings.TrimLeft(indent, " \t") != "" {
+		return 0
+	}
+	return Pos(len(indent))
+}
+
+// trimBlock skips the newline after a block action in TrimBlocks mode.
+func (l *lexer) trimBlock() {
+	if l.mode&TrimBlocks == 0 {
+		return
+	}
+	if strings.HasPrefix(l.input[l.pos:], "\r\n") {
+		l.pos += 2
+	} else if strings.HasPrefix(l.input[l.pos:], "\n") {
+		l.pos++
+	} else {
+		return
+	}
+	l.line++
+	l.ignore()
+}
+
 // rightTrimLength returns the length of the spaces at the end of the string.
 func rightTrimLength(s string) Pos {
 	return Pos(len(s) - len(strings.TrimRight(s, spaceChars)))
@@ -296,8 +376,89 @@ func leftTrimLength(s string) Pos {
 	return Pos(len(s) - len(strings.TrimLeft(s, spaceChars)))
 }
 
+// rawAction reports whether s begins with an action containing only the
+// keyword, such as "{{raw}}" or "{{- endraw -}}", returning its length and
+// whether it has trim markers.
+func (l *lexer) rawAction(s, keyword string) (n Pos, trimBefore, trimAfter, ok bool) {
+	if !strings.HasPrefix(s, l.leftDelim) {
+		return 0, false, false, false
+	}
+	i := len(l.leftDelim)
+	if strings.HasPrefix(s[i:], leftTrimMarker) {
+		trimBefore = true
+		i += len(leftTrimMarker)
+	}
+	i += len(s[i:]) - len(strings.TrimLeft(s[i:], " \t"))
+	if !strings.HasPrefix(s[i:], keyword) {
+		return 0, false, false, false
+	}
+	i += len(keyword)
+	if !strings.HasPrefix(s[i:], l.rightDelim) {
+		spaces := len(s[i:]) - len(strings.TrimLeft(s[i:], " \t"))
+		if spaces == 0 {
+			return 0, false, false, false
+		}
+		i += spaces
+		if strings.HasPrefix(s[i:], "-") && strings.HasPrefix(s[i+1:], l.rightDelim) {
+			trimAfter = true
+			i++
+		} else if !strings.HasPrefix(s[i:], l.rightDelim) {
+			return 0, false, false, false
+		}
+	}
+	return Pos(i + len(l.rightDelim)), trimBefore, trimAfter, true
+}
+
+// lexRaw scans the body of a raw block, emitting it as text without
+// scanning for actions. The raw action is known to be present.
+func lexRaw(l *lexer) stateFn {
+	n, _, trimAfter, _ := l.rawAction(l.input[l.pos:], rawKeyword)
+	l.pos += n
+	if trimAfter {
+		l.pos += leftTrimLength(l.input[l.pos:])
+	}
+	l.ignore()
+	if !trimAfter {
+		l.trimBlock()
+	}
+	for x := l.pos; ; {
+		i := strings.Index(l.input[x:], l.leftDelim)
+		if i < 0 {
+			return l.errorf("unclosed raw block")
+		}
+		x += Pos(i)
+		n, trimBefore, trimAfter, ok := l.rawAction(l.input[x:], endRawKeyword)
+		if !ok {
+			x += Pos(len(l.leftDelim))
+			continue
+		}
+		l.pos = x
+		if trimBefore {
+			l.pos -= rightTrimLength(l.input[l.start:l.pos])
+		} else if l.mode&LstripBlocks != 0 {
+			l.pos -= l.indentLength()
+		}
+		if l.pos > l.start {
+			l.emit(itemText)
+		}
+		l.pos = x + n
+		if trimAfter {
+			l.pos += leftTrimLength(l.input[l.pos:])
+		}
+		l.ignore()
+		if !trimAfter {
+			l.trimBlock()
+		}
+		return lexText
+	}
+}
+
 // lexLeftDelim scans the left delimiter, which is known to be present, possibly with a trim marker.
 func lexLeftDelim(l *lexer) stateFn {
+	if _, _, _, ok := l.rawAction(l.input[l.pos:], rawKeyword); ok {
+		return lexRaw
+	}
+	l.block = l.atBlockAction(l.input[l.pos:])
 	l.pos += Pos(len(l.leftDelim))
 	trimSpace := strings.HasPrefix(l.input[l.pos:], leftTrimMarker)
 	afterMarker := Pos(0)
@@ -336,6 +497,9 @@ func lexComment(l *lexer) stateFn {
 		l.pos += leftTrimLength(l.input[l.pos:])
 	}
 	l.ignore()
+	if !trimSpace {
+		l.trimBlock()
+	}
 	return lexText
 }
 
@@ -351,6 +515,8 @@ func lexRightDelim(l *lexer) stateFn {
 	if trimSpace {
 		l.pos += leftTrimLength(l.input[l.pos:])
 		l.ignore()
+	} else if l.block {
+		l.trimBlock()
 	}
 	return lexText
 }
@@ -307,6 +307,30 @@ var lexTests = []lexTest{
 		mkItem(itemText, "-world"),
 		tEOF,
 	}},
+	{"raw", "hello-{{raw}}{{ if }} {{/* x}}{{end}}{{endraw}}-world", []item{
+		mkItem(itemText, "hello-"),
+		mkItem(itemText, "{{ if }} {{/* x}}{{end}}"),
+		mkItem(itemText, "-world"),
+		tEOF,
+	}},
+	{"trimming spaces in raw", "hello- {{- raw -}} {{.}} {{- endraw -}} -world", []item{
+		mkItem(itemText, "hello-"),
+		mkItem(itemText, "{{.}}"),
+		mkItem(itemText, "-world"),
+		tEOF,
+	}},
+	{"empty raw", "{{ raw }}{{ endraw }}{{.}}", []item{
+		tLeft,
+		tDot,
+		tRight,
+		tEOF,
+	}},
+	{"raw prefix", "{{rawx}}", []item{
+		tLeft,
+		mkItem(itemIdentifier, "rawx"),
+		tRight,
+		tEOF,
+	}},
 	// errors
 	{"badchar", "#{{\x01}}", []item{
 		mkItem(itemText, "#"),
@@ -368,6 +392,10 @@ var lexTests = []lexTest{
 		mkItem(itemText, "hello-"),
 		mkItem(itemError, `unclosed comment`),
 	}},
+	{"unclosed raw", "hello-{{raw}}{{.}}{{end raw}}", []item{
+		mkItem(itemText, "hello-"),
+		mkItem(itemError, "unclosed raw block"),
+	}},
 	{"text with comment close separated from delim", "hello-{{/* */ }}-world", []item{
 		mkItem(itemText, "hello-"),
 		mkItem(itemError, `comment ends before closing delimiter`),
@@ -382,7 +410,7 @@ var lexTests = []lexTest{
 
 // collect gathers the emitted items into a slice.
 func collect(t *lexTest, left, right string) (items []item) {
-	l := lex(t.name, t.input, left, right)
+	l := lex(t.name, t.input, left, right, 0)
 	for {
 		item := l.nextItem()
 		items = append(items, item)
@@ -454,6 +482,54 @@ func TestDelims(t *testing.T) {
 	}
 }
 
+var lexModeTests = []struct {
+	lexTest
+	mode Mode
+}{
+	{lexTest{"trim blocks", "{{if .}}\n  x\n{{end}}\n{{.}}\n", []item{
+		tLeft, mkItem(itemIf, "if"), tSpace, tDot, tRight,
+		mkItem(itemText, "  x\n"),
+		tLeft, mkItem(itemEnd, "end"), tRight,
+		tLeft, tDot, tRight,
+		mkItem(itemText, "\n"),
+		tEOF,
+	}}, TrimBlocks},
+	{lexTest{"lstrip blocks", "a\n  {{if .}}\n  {{.}} {{end}}\n", []item{
+		mkItem(itemText, "a\n"),
+		tLeft, mkItem(itemIf, "if"), tSpace, tDot, tRight,
+		mkItem(itemText, "\n  "),
+		tLeft, tDot, tRight,
+		mkItem(itemText, " "),
+		tLeft, mkItem(itemEnd, "end"), tRight,
+		mkItem(itemText, "\n"),
+		tEOF,
+	}}, LstripBlocks},
+	{lexTest{"trim and lstrip blocks", "x:\n\t{{/* c */}}\r\n\t{{- if .}}\n\t{{raw}}\n{{.}}\n\t{{endraw}}\n\t{{end}}\n", []item{
+		mkItem(itemText, "x:\n"),
+		tLeft, mkItem(itemIf, "if"), tSpace, tDot, tRight,
+		mkItem(itemText, "{{.}}\n"),
+		tLeft, mkItem(itemEnd, "end"), tRight,
+		tEOF,
+	}}, TrimBlocks | LstripBlocks},
+}
+
+func TestLexModes(t *testing.T) {
+	for _, test := range lexModeTests {
+		l := lex(test.name, test.input, "", "", test.mode)
+		var items []item
+		for {
+			item := l.nextItem()
+			items = append(items, item)
+			if item.typ == itemEOF || item.typ == itemError {
+				break
+			}
+		}
+		if !equal(items, test.items, false) {
+			t.Errorf("%s: got\n\t%v\nexpected\n\t%v", test.name, items, test.items)
+		}
+	}
+}
+
 var lexPosTests = []lexTest{
 	{"empty", "", []item{tEOF}},
 	{"punctuation", "{{,@%#}}", []item{
@@ -500,7 +576,7 @@ func TestPos(t *testing.T) {
 func TestShutdown(t *testing.T) {
 	// We need to duplicate template.Parse here to hold on to the lexer.
 	const text = "erroneous{{define}}{{else}}1234"
-	lexer := lex("foo", text, "{{", "}}")
+	lexer := lex("foo", text, "{{", "}}", 0)
 	_, err := New("root").parseLexer(lexer)
 	if err == nil {
 		t.Fatalf("expected error")
@@ -21,6 +21,8 @@ type Tree struct {
 	Name      string    // name of the template represented by the tree.
 	ParseName string    // name of the top-level template during parsing, for error messages.
 	Root      *ListNode // top-level root of the tree.
+	Path      string    // source file of the template, if known.
+	Mode      Mode      // whitespace control used when parsing.
 	text      string    // text parsed to create the template (or its parent)
 	// Parsing only; cleared after parse.
 	funcs     []map[string]interface{}
@@ -40,6 +42,8 @@ func (t *Tree) Copy() *Tree {
 		Name:      t.Name,
 		ParseName: t.ParseName,
 		Root:      t.Root.CopyList(),
+		Path:      t.Path,
+		Mode:      t.Mode,
 		text:      t.text,
 	}
 }
@@ -228,7 +232,7 @@ func (t *Tree) stopParse() {
 func (t *Tree) Parse(text, leftDelim, rightDelim string, treeSet map[string]*Tree, funcs ...map[string]interface{}) (tree *Tree, err error) {
 	defer t.recover(&err)
 	t.ParseName = t.Name
-	t.startParse(funcs, lex(t.Name, text, leftDelim, rightDelim), treeSet)
+	t.startParse(funcs, lex(t.Name, text, leftDelim, rightDelim, t.Mode), treeSet)
 	t.text = text
 	t.parse()
 	t.add()
@@ -286,6 +290,7 @@ func (t *Tree) parse() {
 				newT := New("definition") // name will be updated once we know it.
 				newT.text = t.text
 				newT.ParseName = t.ParseName
+				newT.Mode = t.Mode
 				newT.startParse(t.funcs, t.lex, t.treeSet)
 				newT.parseDefinition()
 				continue
@@ -590,6 +595,7 @@ func (t *Tree) blockControl() Node {
 	pipe := t.pipeline(context)
 
 	block := New(name) // name will be updated once we know it.
+	block.Mode = t.Mode
 	block.text = t.text
 	block.ParseName = t.ParseName
 	block.startParse(t.funcs, t.lex, t.treeSet)
@@ -5,6 +5,7 @@
 package parse
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"strings"
@@ -172,6 +173,10 @@ var parseTests = []parseTest{
 		`" \t\n"`},
 	{"text", "some text", noError,
 		`"some text"`},
+	{"raw", "{{raw}}{{if}}{{endraw}}", noError,
+		`"{{if}}"`},
+	{"unclosed raw", "{{raw}}{{if}}", hasError,
+		``},
 	{"emptyAction", "{{}}", hasError,
 		`{{}}`},
 	{"field", "{{.X}}", noError,
@@ -339,6 +344,50 @@ func TestParseCopy(t *testing.T) {
 	testParse(true, t)
 }
 
+// Encoded trees decode to the same templates.
+func TestEncode(t *testing.T) {
+	textFormat = "%q"
+	defer func() { textFormat = "%s" }()
+	for _, test := range parseTests {
+		if !test.ok {
+			continue
+		}
+		treeSet := make(map[string]*Tree)
+		tree := New(test.name)
+		tree.Mode = TrimBlocks
+		if _, err := tree.Parse(test.input, "", "", treeSet, builtins); err != nil {
+			continue
+		}
+		trees := []*Tree{}
+		for _, tree := range treeSet {
+			trees = append(trees, tree)
+		}
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, trees); err != nil {
+			t.Errorf("%q: encode: %v", test.name, err)
+			continue
+		}
+		decoded, err := Decode(buf)
+		if err != nil {
+			t.Errorf("%q: decode: %v", test.name, err)
+			continue
+		}
+		if len(decoded) != len(trees) {
+			t.Errorf("%q: decoded %d trees, expected %d", test.name, len(decoded), len(trees))
+			continue
+		}
+		for idx, tree := range trees {
+			d := decoded[idx]
+			if d.Name != tree.Name || d.Mode != tree.Mode || d.text != tree.text {
+				t.Errorf("%q: decoded tree %q, expected %q", test.name, d.Name, tree.Name)
+			}
+			if result, expected := d.Root.String(), tree.Root.String(); result != expected {
+				t.Errorf("%q: decoded %s, expected %s", test.name, result, expected)
+			}
+		}
+	}
+}
+
 type isEmptyTest struct {
 	name  string
 	input string
@@ -0,0 +1,53 @@
+// Command plugin is a groom plugin for the tests, built as groom-func-test.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type request struct {
+	ID     int           `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+func main() {
+	out := json.NewEncoder(os.Stdout)
+	out.Encode(map[string]interface{}{
+		"protocol":  1,
+		"functions": []string{"rot13", "words", "fail"},
+	})
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "rot13":
+			resp["result"] = strings.Map(rot13, fmt.Sprint(req.Params...))
+		case "words":
+			resp["result"] = strings.Fields(fmt.Sprint(req.Params...))
+		default:
+			resp["error"] = map[string]interface{}{"code": 1, "message": "failed"}
+		}
+		out.Encode(resp)
+	}
+}
+
+func rot13(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return 'a' + (r-'a'+13)%26
+	case r >= 'A' && r <= 'Z':
+		return 'A' + (r-'A'+13)%26
+	}
+	return r
+}